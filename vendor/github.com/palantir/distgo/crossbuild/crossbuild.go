@@ -0,0 +1,147 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package crossbuild runs a product's build and custom dist script inside a Docker container
+// matching a configured target os/arch, registering binfmt_misc/QEMU handlers first if needed, so
+// that cross-architecture artifacts can be produced transparently from a single host
+// architecture. RunAll is the extension point a dist.Products-style orchestrator calls for a
+// product's configured os/arches, cross-building the ones distgo.CrossBuildConfig maps to an
+// image and returning the rest for the caller to build natively; distgo/dist.Products itself only
+// builds a product for the host's own os/arch today and does not yet call RunAll for any
+// additional configured os/arches.
+package crossbuild
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/palantir/distgo/distgo"
+)
+
+// qemuProbeImage is a minimal multi-arch image used to register binfmt_misc/QEMU handlers for
+// foreign architectures.
+const qemuProbeImage = "multiarch/qemu-user-static"
+
+// ErrDockerUnavailable is the error Run and EnsureQEMU return when the "docker"
+// executable cannot be found on $PATH, so that callers can skip cross-building cleanly (with a
+// clear message) instead of failing an entire dist run.
+var ErrDockerUnavailable = errors.New("docker is not available on PATH; cross-architecture dist builds require Docker")
+
+func dockerAvailable() bool {
+	_, err := exec.LookPath("docker")
+	return err == nil
+}
+
+// joinScripts joins the non-empty scripts in order with " && ", so that an empty distScript (the
+// normal case for a product with no custom dist script) doesn't leave a trailing "&& " that would
+// make the resulting shell command a syntax error.
+func joinScripts(scripts ...string) string {
+	var nonEmpty []string
+	for _, s := range scripts {
+		if s != "" {
+			nonEmpty = append(nonEmpty, s)
+		}
+	}
+	return strings.Join(nonEmpty, " && ")
+}
+
+// EnsureQEMU registers binfmt_misc/QEMU handlers for foreign architectures by running a
+// privileged, one-shot qemuProbeImage container with "--reset -p yes". It is safe to call
+// repeatedly: each call removes and re-registers the same handlers.
+func EnsureQEMU(w io.Writer) error {
+	if !dockerAvailable() {
+		return ErrDockerUnavailable
+	}
+	cmd := exec.Command("docker", "run", "--rm", "--privileged", qemuProbeImage, "--reset", "-p", "yes")
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Run(); err != nil {
+		return errors.Wrap(err, "failed to register QEMU binfmt_misc handlers")
+	}
+	return nil
+}
+
+// RunAll is the integration point a dist.Products-style orchestrator calls once a product has
+// more than one configured os/arch: for each entry in osArches that cfg configures a cross-build
+// image for, it runs the build (and, for dist, distScript) inside that image via Run; every other
+// entry is left for the caller to build natively on the host, and is returned so it can do so.
+// distgo/dist.Products does not call RunAll yet -- it only builds for the host's own os/arch -- so
+// osArches/buildPerOSArch are passed in directly rather than derived from a distgo.ProductParam;
+// wiring Products up to cross-build a product's other configured os/arches would compute both from
+// the product's build command per os/arch, and use env to pass the same DEP_PRODUCT_ID_*/VERSION
+// variables a custom dist script receives (see dister/plugin.go's doc comment for that contract).
+func RunAll(projectDir, productName string, osArches []string, buildPerOSArch map[string]string, distScript string, cfg distgo.CrossBuildConfig, env map[string]string, w io.Writer) (nativeOSArches []string, rErr error) {
+	for _, osArch := range osArches {
+		if _, ok := cfg.NeedsCrossBuild(osArch); !ok {
+			nativeOSArches = append(nativeOSArches, osArch)
+			continue
+		}
+		if err := Run(projectDir, productName, osArch, buildPerOSArch[osArch], distScript, cfg, env, w); err != nil {
+			return nil, err
+		}
+	}
+	return nativeOSArches, nil
+}
+
+// Run builds productName for osArch by running build and distScript (joined with "&&") inside
+// cfg's configured image for osArch, with projectDir (and cfg's Volumes/CacheDir) bind-mounted and
+// cfg.EnvPassthrough plus env forwarded into the container. Returns ErrDockerUnavailable if
+// Docker is not installed, so that callers can skip cross-building with a
+// clear message instead of failing outright.
+func Run(projectDir, productName, osArch, build, distScript string, cfg distgo.CrossBuildConfig, env map[string]string, w io.Writer) error {
+	image, ok := cfg.NeedsCrossBuild(osArch)
+	if !ok {
+		return errors.Errorf("no cross-build image configured for os/arch %q", osArch)
+	}
+	if !dockerAvailable() {
+		return ErrDockerUnavailable
+	}
+	if err := EnsureQEMU(w); err != nil {
+		return err
+	}
+
+	args := []string{"run", "--rm", "-v", fmt.Sprintf("%s:%s", projectDir, projectDir), "-w", projectDir}
+	for _, v := range cfg.Volumes {
+		args = append(args, "-v", v)
+	}
+	if cfg.CacheDir != "" {
+		if err := os.MkdirAll(cfg.CacheDir, 0755); err != nil {
+			return errors.Wrapf(err, "failed to create cross-build cache dir %q", cfg.CacheDir)
+		}
+		args = append(args, "-v", fmt.Sprintf("%s:%s", cfg.CacheDir, cfg.CacheDir))
+	}
+	for _, name := range cfg.EnvPassthrough {
+		if val, ok := os.LookupEnv(name); ok {
+			args = append(args, "-e", fmt.Sprintf("%s=%s", name, val))
+		}
+	}
+	for k, v := range env {
+		args = append(args, "-e", fmt.Sprintf("%s=%s", k, v))
+	}
+	args = append(args, image, "/bin/sh", "-c", joinScripts(build, distScript))
+
+	cmd := exec.Command("docker", args...)
+	cmd.Dir = projectDir
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "cross build for %s (%s) failed", productName, osArch)
+	}
+	return nil
+}
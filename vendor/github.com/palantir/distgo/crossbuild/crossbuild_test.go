@@ -0,0 +1,74 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package crossbuild_test
+
+import (
+	"io/ioutil"
+	"os/exec"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palantir/distgo/crossbuild"
+	"github.com/palantir/distgo/distgo"
+)
+
+func TestRunErrorsWhenOSArchNotConfigured(t *testing.T) {
+	cfg := distgo.CrossBuildConfig{Images: map[string]string{"linux-arm64": "golang:1.20"}}
+	err := crossbuild.Run(".", "foo", "linux-amd64", "go build", "", cfg, nil, ioutil.Discard)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "linux-amd64")
+}
+
+func TestRunSkipsCleanlyWhenDockerUnavailable(t *testing.T) {
+	if _, err := exec.LookPath("docker"); err == nil {
+		t.Skip("docker is available on this host; cannot exercise the unavailable-docker path")
+	}
+
+	cfg := distgo.CrossBuildConfig{Images: map[string]string{"linux-arm64": "golang:1.20"}}
+	err := crossbuild.Run(".", "foo", "linux-arm64", "go build", "", cfg, nil, ioutil.Discard)
+	require.Error(t, err)
+	assert.Equal(t, crossbuild.ErrDockerUnavailable, errors.Cause(err))
+}
+
+func TestRunAllReturnsNativeOSArchesUnbuilt(t *testing.T) {
+	cfg := distgo.CrossBuildConfig{Images: map[string]string{"linux-arm64": "golang:1.20"}}
+	native, err := crossbuild.RunAll(".", "foo", []string{"linux-amd64", "darwin-amd64"}, nil, "", cfg, nil, ioutil.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"linux-amd64", "darwin-amd64"}, native)
+}
+
+func TestRunAllPropagatesCrossBuildFailure(t *testing.T) {
+	if _, err := exec.LookPath("docker"); err == nil {
+		t.Skip("docker is available on this host; cannot exercise the unavailable-docker path")
+	}
+
+	cfg := distgo.CrossBuildConfig{Images: map[string]string{"linux-arm64": "golang:1.20"}}
+	_, err := crossbuild.RunAll(".", "foo", []string{"linux-amd64", "linux-arm64"}, nil, "", cfg, nil, ioutil.Discard)
+	require.Error(t, err)
+	assert.Equal(t, crossbuild.ErrDockerUnavailable, errors.Cause(err))
+}
+
+func TestNeedsCrossBuild(t *testing.T) {
+	cfg := distgo.CrossBuildConfig{Images: map[string]string{"linux-arm64": "golang:1.20"}}
+	image, ok := cfg.NeedsCrossBuild("linux-arm64")
+	assert.True(t, ok)
+	assert.Equal(t, "golang:1.20", image)
+
+	_, ok = cfg.NeedsCrossBuild("linux-amd64")
+	assert.False(t, ok)
+}
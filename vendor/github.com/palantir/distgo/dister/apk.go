@@ -0,0 +1,236 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dister
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ApkDistTypeName is the name of the dister that produces an Alpine (apk) package.
+const ApkDistTypeName = "apk"
+
+// ApkConfig is the YAML-serializable configuration for the apk dister.
+type ApkConfig struct {
+	Package      string            `yaml:"package,omitempty" json:"package,omitempty"`
+	Architecture string            `yaml:"architecture,omitempty" json:"architecture,omitempty"`
+	Metadata     nativePkgMetadata `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+	Files        []fileMapping     `yaml:"files,omitempty" json:"files,omitempty"`
+	Symlinks     []symlink         `yaml:"symlinks,omitempty" json:"symlinks,omitempty"`
+	Scripts      scriptlets        `yaml:"scripts,omitempty" json:"scripts,omitempty"`
+}
+
+// apkDister assembles a staging tree from a product's build outputs and packages it as an apk:
+// a control tar stream (".PKGINFO" plus scriptlets) concatenated with a data tar stream, each
+// independently gzip-compressed as apk requires.
+type apkDister struct {
+	cfg ApkConfig
+}
+
+func newApkDister(cfg ApkConfig) *apkDister {
+	return &apkDister{cfg: cfg}
+}
+
+// TypeName returns the registered name of this dister.
+func (d *apkDister) TypeName() (string, error) {
+	return ApkDistTypeName, nil
+}
+
+// Architecture returns the configured Architecture, defaulting to "x86_64" if unset.
+func (d *apkDister) Architecture() string {
+	if d.cfg.Architecture == "" {
+		return "x86_64"
+	}
+	return d.cfg.Architecture
+}
+
+// ArtifactName returns the name the produced package artifact should be written with.
+func (d *apkDister) ArtifactName(productName, version string) string {
+	pkgName := d.cfg.Package
+	if pkgName == "" {
+		pkgName = productName
+	}
+	return fmt.Sprintf("%s-%s-%s.apk", pkgName, version, d.Architecture())
+}
+
+// Dist stages the product's build outputs and writes an .apk package to distDir/artifactName.
+func (d *apkDister) Dist(buildOutputDir, distDir, productName, version string) (string, error) {
+	staged, err := stageFiles(buildOutputDir, d.cfg.Files, d.cfg.Symlinks)
+	if err != nil {
+		return "", err
+	}
+
+	dataTar, sha1sums, dataSize, err := buildApkDataTarball(staged)
+	if err != nil {
+		return "", err
+	}
+	controlTar, err := buildApkControlTarball(d, productName, version, dataSize, sha1sums)
+	if err != nil {
+		return "", err
+	}
+
+	artifactName := d.ArtifactName(productName, version)
+	artifactPath := fmt.Sprintf("%s/%s", distDir, artifactName)
+	out, err := os.Create(artifactPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create %q", artifactPath)
+	}
+	defer func() { _ = out.Close() }()
+
+	// apk packages are the concatenation of independently-gzipped tar streams: signature (not
+	// produced here, as these packages are unsigned), control and data.
+	if _, err := out.Write(controlTar); err != nil {
+		return "", errors.Wrapf(err, "failed to write %q", artifactPath)
+	}
+	if _, err := out.Write(dataTar); err != nil {
+		return "", errors.Wrapf(err, "failed to write %q", artifactPath)
+	}
+	return artifactPath, nil
+}
+
+func buildApkDataTarball(staged []stagedFile) (tarball []byte, sha1sums map[string]string, totalSize int64, rErr error) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	sums := map[string]string{}
+	var size int64
+	for _, f := range staged {
+		name := strings.TrimPrefix(f.dst, "/")
+		if f.linkDest != "" {
+			hdr := &tar.Header{
+				Name:     name,
+				Typeflag: tar.TypeSymlink,
+				Linkname: f.linkDest,
+				Mode:     int64(f.mode.Perm()),
+				ModTime:  time.Unix(0, 0),
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return nil, nil, 0, errors.Wrap(err, "failed to write apk data header")
+			}
+			continue
+		}
+		contents, err := readStagedFile(f)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		hdr := &tar.Header{
+			Name:    name,
+			Size:    int64(len(contents)),
+			Mode:    int64(f.mode.Perm()),
+			ModTime: time.Unix(0, 0),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, nil, 0, errors.Wrap(err, "failed to write apk data header")
+		}
+		if _, err := tw.Write(contents); err != nil {
+			return nil, nil, 0, errors.Wrap(err, "failed to write apk data content")
+		}
+		sum := sha1.Sum(contents)
+		sums[name] = hex.EncodeToString(sum[:])
+		size += int64(len(contents))
+	}
+	if err := tw.Close(); err != nil {
+		return nil, nil, 0, errors.Wrap(err, "failed to close apk data tarball")
+	}
+	if err := gw.Close(); err != nil {
+		return nil, nil, 0, errors.Wrap(err, "failed to close apk data tarball")
+	}
+	return buf.Bytes(), sums, size, nil
+}
+
+func buildApkControlTarball(d *apkDister, productName, version string, installedSize int64, sha1sums map[string]string) ([]byte, error) {
+	pkgName := d.cfg.Package
+	if pkgName == "" {
+		pkgName = productName
+	}
+	var pkginfo strings.Builder
+	fmt.Fprintf(&pkginfo, "pkgname = %s\n", pkgName)
+	fmt.Fprintf(&pkginfo, "pkgver = %s\n", version)
+	fmt.Fprintf(&pkginfo, "arch = %s\n", d.Architecture())
+	fmt.Fprintf(&pkginfo, "size = %d\n", installedSize)
+	if d.cfg.Metadata.Description != "" {
+		fmt.Fprintf(&pkginfo, "pkgdesc = %s\n", d.cfg.Metadata.Description)
+	}
+	if d.cfg.Metadata.Homepage != "" {
+		fmt.Fprintf(&pkginfo, "url = %s\n", d.cfg.Metadata.Homepage)
+	}
+	if d.cfg.Metadata.License != "" {
+		fmt.Fprintf(&pkginfo, "license = %s\n", d.cfg.Metadata.License)
+	}
+	for _, dep := range d.cfg.Metadata.Dependencies {
+		fmt.Fprintf(&pkginfo, "depend = %s\n", dep)
+	}
+	// sha1sums are recorded per-file in .PKGINFO so that `apk verify` can validate the data
+	// payload without re-reading the whole archive. Sorted for reproducible output.
+	names := make([]string, 0, len(sha1sums))
+	for name := range sha1sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&pkginfo, "datahash = %s  %s\n", sha1sums[name], name)
+	}
+
+	members := map[string]string{
+		".PKGINFO":        pkginfo.String(),
+		".pre-install":    d.cfg.Scripts.PreInstall,
+		".post-install":   d.cfg.Scripts.PostInstall,
+		".pre-deinstall":  d.cfg.Scripts.PreRemove,
+		".post-deinstall": d.cfg.Scripts.PostRemove,
+	}
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for _, name := range []string{".PKGINFO", ".pre-install", ".post-install", ".pre-deinstall", ".post-deinstall"} {
+		content := members[name]
+		if content == "" {
+			continue
+		}
+		m := os.FileMode(0644)
+		if name != ".PKGINFO" {
+			m = 0755
+		}
+		hdr := &tar.Header{
+			Name:    name,
+			Size:    int64(len(content)),
+			Mode:    int64(m),
+			ModTime: time.Unix(0, 0),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, errors.Wrap(err, "failed to write apk control header")
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			return nil, errors.Wrap(err, "failed to write apk control content")
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close apk control tarball")
+	}
+	if err := gw.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close apk control tarball")
+	}
+	return buf.Bytes(), nil
+}
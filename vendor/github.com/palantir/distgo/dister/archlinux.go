@@ -0,0 +1,221 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dister
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ArchlinuxDistTypeName is the name of the dister that produces an Arch Linux package.
+const ArchlinuxDistTypeName = "archlinux"
+
+// ArchlinuxConfig is the YAML-serializable configuration for the archlinux dister.
+type ArchlinuxConfig struct {
+	Package      string            `yaml:"package,omitempty" json:"package,omitempty"`
+	Architecture string            `yaml:"architecture,omitempty" json:"architecture,omitempty"`
+	Metadata     nativePkgMetadata `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+	Files        []fileMapping     `yaml:"files,omitempty" json:"files,omitempty"`
+	Symlinks     []symlink         `yaml:"symlinks,omitempty" json:"symlinks,omitempty"`
+	Scripts      scriptlets        `yaml:"scripts,omitempty" json:"scripts,omitempty"`
+}
+
+// archlinuxDister assembles a staging tree from a product's build outputs and packages it as an
+// Arch Linux package: a compressed tar archive containing the payload plus ".PKGINFO" and
+// ".MTREE" metadata files.
+//
+// Real pacman packages are zstd-compressed; this dister writes a gzip-compressed tarball with a
+// ".pkg.tar.gz" extension instead, since zstd is not available without an additional dependency.
+type archlinuxDister struct {
+	cfg ArchlinuxConfig
+}
+
+func newArchlinuxDister(cfg ArchlinuxConfig) *archlinuxDister {
+	return &archlinuxDister{cfg: cfg}
+}
+
+// TypeName returns the registered name of this dister.
+func (d *archlinuxDister) TypeName() (string, error) {
+	return ArchlinuxDistTypeName, nil
+}
+
+// Architecture returns the configured Architecture, defaulting to "x86_64" if unset.
+func (d *archlinuxDister) Architecture() string {
+	if d.cfg.Architecture == "" {
+		return "x86_64"
+	}
+	return d.cfg.Architecture
+}
+
+// ArtifactName returns the name the produced package artifact should be written with.
+func (d *archlinuxDister) ArtifactName(productName, version string) string {
+	pkgName := d.cfg.Package
+	if pkgName == "" {
+		pkgName = productName
+	}
+	return fmt.Sprintf("%s-%s-%s.pkg.tar.gz", pkgName, version, d.Architecture())
+}
+
+// Dist stages the product's build outputs and writes an Arch Linux package to
+// distDir/artifactName.
+func (d *archlinuxDister) Dist(buildOutputDir, distDir, productName, version string) (string, error) {
+	staged, err := stageFiles(buildOutputDir, d.cfg.Files, d.cfg.Symlinks)
+	if err != nil {
+		return "", err
+	}
+
+	pkgName := d.cfg.Package
+	if pkgName == "" {
+		pkgName = productName
+	}
+
+	artifactName := d.ArtifactName(productName, version)
+	artifactPath := fmt.Sprintf("%s/%s", distDir, artifactName)
+	out, err := os.Create(artifactPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create %q", artifactPath)
+	}
+	defer func() { _ = out.Close() }()
+
+	gw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gw)
+
+	var mtree strings.Builder
+	mtree.WriteString("#mtree\n")
+	var installedSize int64
+	for _, f := range staged {
+		name := strings.TrimPrefix(f.dst, "/")
+		if f.linkDest != "" {
+			hdr := &tar.Header{
+				Name:     name,
+				Typeflag: tar.TypeSymlink,
+				Linkname: f.linkDest,
+				Mode:     int64(f.mode.Perm()),
+				ModTime:  time.Unix(0, 0),
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return "", errors.Wrap(err, "failed to write archlinux package header")
+			}
+			fmt.Fprintf(&mtree, "./%s type=link link=%s\n", name, f.linkDest)
+			continue
+		}
+		contents, err := readStagedFile(f)
+		if err != nil {
+			return "", err
+		}
+		hdr := &tar.Header{
+			Name:    name,
+			Size:    int64(len(contents)),
+			Mode:    int64(f.mode.Perm()),
+			ModTime: time.Unix(0, 0),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return "", errors.Wrap(err, "failed to write archlinux package header")
+		}
+		if _, err := tw.Write(contents); err != nil {
+			return "", errors.Wrap(err, "failed to write archlinux package content")
+		}
+		sum := sha256.Sum256(contents)
+		fmt.Fprintf(&mtree, "./%s type=file mode=%o size=%d sha256digest=%s\n", name, f.mode.Perm(), len(contents), hex.EncodeToString(sum[:]))
+		installedSize += int64(len(contents))
+	}
+
+	var pkginfo strings.Builder
+	fmt.Fprintf(&pkginfo, "pkgname = %s\n", pkgName)
+	fmt.Fprintf(&pkginfo, "pkgver = %s-1\n", version)
+	fmt.Fprintf(&pkginfo, "pkgarch = %s\n", d.Architecture())
+	fmt.Fprintf(&pkginfo, "size = %d\n", installedSize)
+	if d.cfg.Metadata.Description != "" {
+		fmt.Fprintf(&pkginfo, "pkgdesc = %s\n", d.cfg.Metadata.Description)
+	}
+	if d.cfg.Metadata.Homepage != "" {
+		fmt.Fprintf(&pkginfo, "url = %s\n", d.cfg.Metadata.Homepage)
+	}
+	if d.cfg.Metadata.License != "" {
+		fmt.Fprintf(&pkginfo, "license = %s\n", d.cfg.Metadata.License)
+	}
+	for _, dep := range d.cfg.Metadata.Dependencies {
+		fmt.Fprintf(&pkginfo, "depend = %s\n", dep)
+	}
+	for _, c := range d.cfg.Metadata.Conflicts {
+		fmt.Fprintf(&pkginfo, "conflict = %s\n", c)
+	}
+	for _, r := range d.cfg.Metadata.Replaces {
+		fmt.Fprintf(&pkginfo, "replaces = %s\n", r)
+	}
+
+	for _, member := range []struct {
+		name    string
+		content string
+		mode    int64
+	}{
+		{".PKGINFO", pkginfo.String(), 0644},
+		{".MTREE", mtree.String(), 0644},
+		{".INSTALL", archlinuxInstallScript(d.cfg.Scripts), 0755},
+	} {
+		if member.content == "" {
+			continue
+		}
+		hdr := &tar.Header{
+			Name:    member.name,
+			Size:    int64(len(member.content)),
+			Mode:    member.mode,
+			ModTime: time.Unix(0, 0),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return "", errors.Wrap(err, "failed to write archlinux metadata header")
+		}
+		if _, err := tw.Write([]byte(member.content)); err != nil {
+			return "", errors.Wrap(err, "failed to write archlinux metadata content")
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return "", errors.Wrapf(err, "failed to close %q", artifactPath)
+	}
+	if err := gw.Close(); err != nil {
+		return "", errors.Wrapf(err, "failed to close %q", artifactPath)
+	}
+	return artifactPath, nil
+}
+
+// archlinuxInstallScript renders the configured scriptlets as a makepkg-style .INSTALL file
+// exposing pre_install/post_install/pre_remove/post_remove shell functions. Returns "" if none
+// of the scriptlets are configured.
+func archlinuxInstallScript(s scriptlets) string {
+	if s.PreInstall == "" && s.PostInstall == "" && s.PreRemove == "" && s.PostRemove == "" {
+		return ""
+	}
+	var b strings.Builder
+	writeFunc := func(name, body string) {
+		if body == "" {
+			return
+		}
+		fmt.Fprintf(&b, "%s() {\n%s\n}\n", name, body)
+	}
+	writeFunc("pre_install", s.PreInstall)
+	writeFunc("post_install", s.PostInstall)
+	writeFunc("pre_remove", s.PreRemove)
+	writeFunc("post_remove", s.PostRemove)
+	return b.String()
+}
@@ -0,0 +1,277 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dister
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DebDistTypeName is the name of the dister that produces a Debian binary package.
+const DebDistTypeName = "deb"
+
+// DebConfig is the YAML-serializable configuration for the deb dister.
+type DebConfig struct {
+	Package      string            `yaml:"package,omitempty" json:"package,omitempty"`
+	Architecture string            `yaml:"architecture,omitempty" json:"architecture,omitempty"`
+	Metadata     nativePkgMetadata `yaml:"metadata,omitempty" json:"metadata,omitempty"`
+	Files        []fileMapping     `yaml:"files,omitempty" json:"files,omitempty"`
+	Symlinks     []symlink         `yaml:"symlinks,omitempty" json:"symlinks,omitempty"`
+	ConfFiles    []string          `yaml:"confFiles,omitempty" json:"confFiles,omitempty"`
+	Changelog    []changelogEntry  `yaml:"changelog,omitempty" json:"changelog,omitempty"`
+	Scripts      scriptlets        `yaml:"scripts,omitempty" json:"scripts,omitempty"`
+}
+
+// debDister assembles a staging tree from a product's build outputs and the configured file
+// mappings and packages it as a Debian binary package: an "ar" archive containing
+// "debian-binary", "control.tar.gz" and "data.tar.gz" members.
+type debDister struct {
+	cfg DebConfig
+}
+
+func newDebDister(cfg DebConfig) *debDister {
+	return &debDister{cfg: cfg}
+}
+
+// TypeName returns the registered name of this dister.
+func (d *debDister) TypeName() (string, error) {
+	return DebDistTypeName, nil
+}
+
+// Architecture returns the configured Architecture, defaulting to "amd64" if unset.
+func (d *debDister) Architecture() string {
+	if d.cfg.Architecture == "" {
+		return "amd64"
+	}
+	return d.cfg.Architecture
+}
+
+// ArtifactName returns the name the produced package artifact should be written with, following
+// the "<product>-<version>-<arch>.<ext>" convention shared by all of the native package disters.
+func (d *debDister) ArtifactName(productName, version string) string {
+	pkgName := d.cfg.Package
+	if pkgName == "" {
+		pkgName = productName
+	}
+	return fmt.Sprintf("%s-%s-%s.deb", pkgName, version, d.Architecture())
+}
+
+// Dist stages the product's build outputs according to the configured file mappings and symlinks
+// and writes a .deb package to distDir/artifactName.
+func (d *debDister) Dist(buildOutputDir, distDir, productName, version string) (string, error) {
+	staged, err := stageFiles(buildOutputDir, d.cfg.Files, d.cfg.Symlinks)
+	if err != nil {
+		return "", err
+	}
+
+	dataTar, md5sums, err := buildDebDataTarball(staged)
+	if err != nil {
+		return "", err
+	}
+	controlTar, err := buildDebControlTarball(d, productName, version, md5sums)
+	if err != nil {
+		return "", err
+	}
+
+	artifactName := d.ArtifactName(productName, version)
+	artifactPath := fmt.Sprintf("%s/%s", distDir, artifactName)
+	out, err := os.Create(artifactPath)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to create %q", artifactPath)
+	}
+	defer func() { _ = out.Close() }()
+
+	if err := writeAr(out, []arMember{
+		{name: "debian-binary", content: []byte("2.0\n")},
+		{name: "control.tar.gz", content: controlTar},
+		{name: "data.tar.gz", content: dataTar},
+	}); err != nil {
+		return "", errors.Wrapf(err, "failed to write %q", artifactPath)
+	}
+	return artifactPath, nil
+}
+
+func buildDebDataTarball(staged []stagedFile) (tarball []byte, md5sums string, rErr error) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	var md5Builder strings.Builder
+	for _, f := range staged {
+		dst := "./" + strings.TrimPrefix(f.dst, "/")
+		if f.linkDest != "" {
+			hdr := &tar.Header{
+				Name:     dst,
+				Typeflag: tar.TypeSymlink,
+				Linkname: f.linkDest,
+				Mode:     int64(f.mode.Perm()),
+				ModTime:  time.Unix(0, 0),
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return nil, "", errors.Wrap(err, "failed to write data.tar.gz header")
+			}
+			continue
+		}
+		contents, err := readStagedFile(f)
+		if err != nil {
+			return nil, "", err
+		}
+		hdr := &tar.Header{
+			Name:    dst,
+			Size:    int64(len(contents)),
+			Mode:    int64(f.mode.Perm()),
+			ModTime: time.Unix(0, 0),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, "", errors.Wrap(err, "failed to write data.tar.gz header")
+		}
+		if _, err := tw.Write(contents); err != nil {
+			return nil, "", errors.Wrap(err, "failed to write data.tar.gz content")
+		}
+		sum := md5.Sum(contents)
+		fmt.Fprintf(&md5Builder, "%s  %s\n", hex.EncodeToString(sum[:]), strings.TrimPrefix(dst, "./"))
+	}
+	if err := tw.Close(); err != nil {
+		return nil, "", errors.Wrap(err, "failed to close data.tar.gz")
+	}
+	if err := gw.Close(); err != nil {
+		return nil, "", errors.Wrap(err, "failed to close data.tar.gz")
+	}
+	return buf.Bytes(), md5Builder.String(), nil
+}
+
+func buildDebControlTarball(d *debDister, productName, version, md5sums string) ([]byte, error) {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	pkgName := d.cfg.Package
+	if pkgName == "" {
+		pkgName = productName
+	}
+	var control strings.Builder
+	fmt.Fprintf(&control, "Package: %s\n", pkgName)
+	fmt.Fprintf(&control, "Version: %s\n", version)
+	fmt.Fprintf(&control, "Architecture: %s\n", d.Architecture())
+	if d.cfg.Metadata.Maintainer != "" {
+		fmt.Fprintf(&control, "Maintainer: %s\n", d.cfg.Metadata.Maintainer)
+	}
+	if d.cfg.Metadata.Section != "" {
+		fmt.Fprintf(&control, "Section: %s\n", d.cfg.Metadata.Section)
+	}
+	if d.cfg.Metadata.Homepage != "" {
+		fmt.Fprintf(&control, "Homepage: %s\n", d.cfg.Metadata.Homepage)
+	}
+	if len(d.cfg.Metadata.Dependencies) > 0 {
+		fmt.Fprintf(&control, "Depends: %s\n", strings.Join(d.cfg.Metadata.Dependencies, ", "))
+	}
+	if len(d.cfg.Metadata.Conflicts) > 0 {
+		fmt.Fprintf(&control, "Conflicts: %s\n", strings.Join(d.cfg.Metadata.Conflicts, ", "))
+	}
+	if len(d.cfg.Metadata.Replaces) > 0 {
+		fmt.Fprintf(&control, "Replaces: %s\n", strings.Join(d.cfg.Metadata.Replaces, ", "))
+	}
+	fmt.Fprintf(&control, "Description: %s\n", firstNonEmpty(d.cfg.Metadata.Description, productName))
+
+	members := map[string]string{
+		"control":  control.String(),
+		"md5sums":  md5sums,
+		"preinst":  d.cfg.Scripts.PreInstall,
+		"postinst": d.cfg.Scripts.PostInstall,
+		"prerm":    d.cfg.Scripts.PreRemove,
+		"postrm":   d.cfg.Scripts.PostRemove,
+	}
+	if len(d.cfg.ConfFiles) > 0 {
+		members["conffiles"] = strings.Join(d.cfg.ConfFiles, "\n") + "\n"
+	}
+	for _, name := range []string{"control", "md5sums", "conffiles", "preinst", "postinst", "prerm", "postrm"} {
+		content, ok := members[name]
+		if !ok || content == "" {
+			continue
+		}
+		m := os.FileMode(0644)
+		if name != "control" && name != "md5sums" && name != "conffiles" {
+			m = 0755
+		}
+		hdr := &tar.Header{
+			Name:    "./" + name,
+			Size:    int64(len(content)),
+			Mode:    int64(m),
+			ModTime: time.Unix(0, 0),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, errors.Wrap(err, "failed to write control.tar.gz header")
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			return nil, errors.Wrap(err, "failed to write control.tar.gz content")
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close control.tar.gz")
+	}
+	if err := gw.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close control.tar.gz")
+	}
+	return buf.Bytes(), nil
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// arMember is a single named entry in an ar archive.
+type arMember struct {
+	name    string
+	content []byte
+}
+
+// writeAr writes members as a "common" (GNU/BSD-compatible) ar archive, which is the container
+// format used by Debian binary packages.
+func writeAr(w *os.File, members []arMember) error {
+	if _, err := w.WriteString("!<arch>\n"); err != nil {
+		return err
+	}
+	for _, m := range members {
+		size := len(m.content)
+		// mtime is zeroed (rather than time.Now()) so that repeated builds of the same inputs
+		// produce byte-identical archives.
+		header := fmt.Sprintf("%-16s%-12d%-6d%-6d%-8s%-10d`\n", m.name, 0, 0, 0, "100644", size)
+		if _, err := w.WriteString(header); err != nil {
+			return err
+		}
+		if _, err := w.Write(m.content); err != nil {
+			return err
+		}
+		if size%2 != 0 {
+			if _, err := w.WriteString("\n"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
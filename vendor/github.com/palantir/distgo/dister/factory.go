@@ -0,0 +1,109 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dister provides the built-in distgo.Dister implementations (the logic that turns a
+// product's build output into distributable artifacts) and the factory used to look them up by
+// their configured type name.
+package dister
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"github.com/palantir/distgo/distgo"
+)
+
+// OSArchBinDistTypeName is the name of the default dister, which packages a product's built
+// binaries for the current (or a configured) os/arch as a ".tgz" archive.
+const OSArchBinDistTypeName = "os-arch-bin"
+
+// NewDisterFactory returns a distgo.DisterFactory with all of the built-in dister types
+// registered (the default os-arch-bin archive dister, the native OS package disters (deb, apk,
+// archlinux), and the daemonless oci dister), plus any third-party dister plugins discovered
+// on $PATH or plugin.DirEnvVar (see the dister/plugin.go doc comment).
+//
+// There is intentionally no built-in rpm dister: producing a real, installable rpm requires
+// writing the binary header/tag/index format used by rpm's signature and header regions, which is
+// beyond what this package implements. A correct rpm dister should be added as a plugin (see
+// dister/plugin.go) backed by a real encoder, or vendored here, rather than resurrecting an ad hoc
+// approximation that no rpm/dnf/yum can parse.
+func NewDisterFactory() (*distgo.DisterFactory, error) {
+	factory, err := distgo.NewDisterFactory()
+	if err != nil {
+		return nil, err
+	}
+	registrations := []struct {
+		typeName string
+		creator  distgo.DisterCreator
+	}{
+		{OSArchBinDistTypeName, newOSArchBinDisterFromConfig},
+		{DebDistTypeName, newDebDisterFromConfig},
+		{ApkDistTypeName, newApkDisterFromConfig},
+		{ArchlinuxDistTypeName, newArchlinuxDisterFromConfig},
+		{OCIDistTypeName, newOCIDisterFromConfig},
+	}
+	for _, r := range registrations {
+		if err := factory.Register(r.typeName, r.creator); err != nil {
+			return nil, errors.Wrapf(err, "failed to register dister type %q", r.typeName)
+		}
+	}
+	if err := registerPlugins(factory); err != nil {
+		return nil, err
+	}
+	return factory, nil
+}
+
+// DefaultConfig returns the DisterConfig used when a product does not explicitly configure a
+// dister: an os-arch-bin dister for the current os/arch.
+func DefaultConfig() (distgo.DisterConfig, error) {
+	return distgo.DisterConfig{
+		Type:   OSArchBinDistTypeName,
+		Config: "{}",
+	}, nil
+}
+
+func newDebDisterFromConfig(cfgYAML string) (distgo.Dister, error) {
+	var cfg DebConfig
+	if err := unmarshalDisterConfig(cfgYAML, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal %s config", DebDistTypeName)
+	}
+	return &nativePkgDister{typeName: DebDistTypeName, format: newDebDister(cfg)}, nil
+}
+
+func newApkDisterFromConfig(cfgYAML string) (distgo.Dister, error) {
+	var cfg ApkConfig
+	if err := unmarshalDisterConfig(cfgYAML, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal %s config", ApkDistTypeName)
+	}
+	return &nativePkgDister{typeName: ApkDistTypeName, format: newApkDister(cfg)}, nil
+}
+
+func newArchlinuxDisterFromConfig(cfgYAML string) (distgo.Dister, error) {
+	var cfg ArchlinuxConfig
+	if err := unmarshalDisterConfig(cfgYAML, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal %s config", ArchlinuxDistTypeName)
+	}
+	return &nativePkgDister{typeName: ArchlinuxDistTypeName, format: newArchlinuxDister(cfg)}, nil
+}
+
+// unmarshalDisterConfig decodes a dister's configuration payload. Treats the empty string as an
+// empty object so that disters can be used with no configuration at all. The payload is decoded as
+// JSON, so a YAML payload that is not also valid JSON is rejected.
+func unmarshalDisterConfig(cfgYAML string, out interface{}) error {
+	if cfgYAML == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(cfgYAML), out)
+}
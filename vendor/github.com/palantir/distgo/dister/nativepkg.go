@@ -0,0 +1,240 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dister
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// nativePkgMetadata captures the package metadata that is common to all of the native OS package
+// formats (deb, apk and archlinux). Individual formats translate this into their own control/
+// PKGINFO representation.
+type nativePkgMetadata struct {
+	Maintainer   string   `yaml:"maintainer,omitempty" json:"maintainer,omitempty"`
+	License      string   `yaml:"license,omitempty" json:"license,omitempty"`
+	Section      string   `yaml:"section,omitempty" json:"section,omitempty"`
+	Homepage     string   `yaml:"homepage,omitempty" json:"homepage,omitempty"`
+	Description  string   `yaml:"description,omitempty" json:"description,omitempty"`
+	Dependencies []string `yaml:"dependencies,omitempty" json:"dependencies,omitempty"`
+	Conflicts    []string `yaml:"conflicts,omitempty" json:"conflicts,omitempty"`
+	Replaces     []string `yaml:"replaces,omitempty" json:"replaces,omitempty"`
+}
+
+// fileMapping maps a source glob (resolved relative to the product's build output directory) to
+// a destination path within the package, along with the mode that the destination entry is
+// written with. Owner and Group are accepted but not applied: every format writes its entries
+// with uid/gid 0 and no owner or group name.
+type fileMapping struct {
+	Src   string `yaml:"src" json:"src"`
+	Dst   string `yaml:"dst" json:"dst"`
+	Mode  int64  `yaml:"mode,omitempty" json:"mode,omitempty"`
+	Owner string `yaml:"owner,omitempty" json:"owner,omitempty"`
+	Group string `yaml:"group,omitempty" json:"group,omitempty"`
+}
+
+// symlink represents a symbolic link that should be created inside the staged package tree.
+type symlink struct {
+	Link   string `yaml:"link" json:"link"`
+	Target string `yaml:"target" json:"target"`
+}
+
+// changelogEntry is a single entry in the package's changelog. Only DebConfig accepts changelog
+// entries, and the deb dister does not write them into the package.
+type changelogEntry struct {
+	Version string   `yaml:"version" json:"version"`
+	Date    string   `yaml:"date" json:"date"`
+	Author  string   `yaml:"author" json:"author"`
+	Changes []string `yaml:"changes" json:"changes"`
+}
+
+// scriptlets holds the lifecycle scripts that are invoked by the package manager at the
+// corresponding points in the package's install/remove lifecycle. Not every format supports every
+// scriptlet.
+type scriptlets struct {
+	PreInstall  string `yaml:"preInstall,omitempty" json:"preInstall,omitempty"`
+	PostInstall string `yaml:"postInstall,omitempty" json:"postInstall,omitempty"`
+	PreRemove   string `yaml:"preRemove,omitempty" json:"preRemove,omitempty"`
+	PostRemove  string `yaml:"postRemove,omitempty" json:"postRemove,omitempty"`
+}
+
+const defaultFileMode = 0644
+
+func mode(m int64) os.FileMode {
+	if m == 0 {
+		return os.FileMode(defaultFileMode)
+	}
+	return os.FileMode(m)
+}
+
+// stagedFile is a single resolved entry (either a regular file or a symlink) that is destined for
+// the package payload, sorted by destination so that every format produces reproducible output.
+// Entries with the same destination are not deduplicated.
+type stagedFile struct {
+	dst      string
+	srcPath  string
+	linkDest string
+	mode     os.FileMode
+}
+
+// stageFiles resolves the configured file mappings and symlinks against buildOutputDir (the
+// product's built binaries) and returns the set of files that should be written into the
+// package payload, sorted by destination path for reproducibility.
+func stageFiles(buildOutputDir string, mappings []fileMapping, symlinks []symlink) ([]stagedFile, error) {
+	var staged []stagedFile
+	for _, m := range mappings {
+		matches, err := filepath.Glob(filepath.Join(buildOutputDir, m.Src))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resolve file mapping glob %q", m.Src)
+		}
+		if len(matches) == 0 {
+			return nil, errors.Errorf("file mapping glob %q did not match any files in %s", m.Src, buildOutputDir)
+		}
+		if len(matches) > 1 && !strings.HasSuffix(m.Dst, "/") {
+			return nil, errors.Errorf("file mapping glob %q matches multiple files, so Dst %q must end with \"/\" to be used as a destination directory", m.Src, m.Dst)
+		}
+		for _, match := range matches {
+			dst := m.Dst
+			if len(matches) > 1 {
+				dst = filepath.Join(m.Dst, filepath.Base(match))
+			}
+			staged = append(staged, stagedFile{
+				dst:     filepath.ToSlash(dst),
+				srcPath: match,
+				mode:    mode(m.Mode),
+			})
+		}
+	}
+	for _, s := range symlinks {
+		staged = append(staged, stagedFile{
+			dst:      filepath.ToSlash(s.Link),
+			linkDest: s.Target,
+			mode:     os.ModeSymlink | 0777,
+		})
+	}
+	sort.Slice(staged, func(i, j int) bool {
+		return staged[i].dst < staged[j].dst
+	})
+	return staged, nil
+}
+
+// readStagedFile returns the contents of a staged regular file. It is an error to call this for
+// a symlink entry.
+func readStagedFile(f stagedFile) ([]byte, error) {
+	if f.linkDest != "" {
+		return nil, errors.Errorf("cannot read contents of symlink entry %q", f.dst)
+	}
+	bytes, err := ioutil.ReadFile(f.srcPath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read staged file %q", f.srcPath)
+	}
+	return bytes, nil
+}
+
+// singleArchFormat is the subset of behavior that each native OS package format (deb, apk,
+// archlinux) implements. Unlike os-arch-bin, these formats produce a single artifact for a
+// single target architecture, so nativePkgDister adapts them to the (possibly multi-arch)
+// distgo.Dister interface.
+type singleArchFormat interface {
+	// Architecture returns the target architecture this format is configured to package,
+	// defaulting to the format's conventional default architecture if unset. Used to pick which
+	// of a product's (possibly per-os/arch) build output directories to package when there is more
+	// than one.
+	Architecture() string
+	ArtifactName(productName, version string) string
+	Dist(buildOutputDir, distDir, productName, version string) (string, error)
+}
+
+// nativePkgDister adapts a singleArchFormat (one of the concrete native package formats) to the
+// distgo.Dister interface expected by the factory.
+type nativePkgDister struct {
+	typeName string
+	format   singleArchFormat
+}
+
+// TypeName returns the registered name of this dister.
+func (d *nativePkgDister) TypeName() (string, error) {
+	return d.typeName, nil
+}
+
+// Artifacts returns the single package artifact that Dist produces.
+func (d *nativePkgDister) Artifacts(productName, version string) ([]string, error) {
+	return []string{d.format.ArtifactName(productName, version)}, nil
+}
+
+// Dist picks the build output directory matching the format's target architecture (or the sole
+// build output directory, if there is only one) and delegates to the format implementation.
+func (d *nativePkgDister) Dist(productName, version string, buildOutputDirs map[string]string, distDir string) ([]string, error) {
+	buildOutputDir, err := pickBuildOutputDir(buildOutputDirs, d.format.Architecture())
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s dister requires an unambiguous build output directory", d.typeName)
+	}
+	artifactPath, err := d.format.Dist(buildOutputDir, distDir, productName, version)
+	if err != nil {
+		return nil, err
+	}
+	return []string{filepath.Base(artifactPath)}, nil
+}
+
+// pickBuildOutputDir selects which of a product's (possibly per-os/arch) build output
+// directories a single-architecture format dister should package. If there is exactly one build
+// output, it is used unconditionally; otherwise architecture (the format's configured or default
+// architecture) must match the arch component of exactly one "os-arch" key so that the choice is
+// unambiguous.
+func pickBuildOutputDir(buildOutputDirs map[string]string, architecture string) (string, error) {
+	if len(buildOutputDirs) == 0 {
+		return "", errors.New("no build output directories were provided")
+	}
+	if len(buildOutputDirs) == 1 {
+		for _, dir := range buildOutputDirs {
+			return dir, nil
+		}
+	}
+	var matchedDir string
+	matches := 0
+	for osArch, dir := range buildOutputDirs {
+		if strings.HasSuffix(osArch, "-"+architecture) {
+			matchedDir = dir
+			matches++
+		}
+	}
+	if matches == 1 {
+		return matchedDir, nil
+	}
+	if matches > 1 {
+		return "", errors.Errorf("architecture %q matches more than one build output directory (%v); configure an os/arch-specific build or a more specific architecture to disambiguate", architecture, buildOutputDirs)
+	}
+	return "", errors.Errorf("multiple build output directories are available (%v) and none match configured architecture %q; configure an explicit architecture matching one of the os/arch keys to disambiguate", buildOutputDirs, architecture)
+}
+
+// copyFile copies the contents of src into w, returning the number of bytes written.
+func copyFile(w io.Writer, src string) (int64, error) {
+	srcFile, err := os.Open(src)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to open %q", src)
+	}
+	defer func() { _ = srcFile.Close() }()
+	n, err := io.Copy(w, srcFile)
+	if err != nil {
+		return n, errors.Wrapf(err, "failed to copy %q", src)
+	}
+	return n, nil
+}
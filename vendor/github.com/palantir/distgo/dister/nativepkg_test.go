@@ -0,0 +1,136 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dister
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDisterFactoryRegistersNativePackageTypes(t *testing.T) {
+	factory, err := NewDisterFactory()
+	require.NoError(t, err)
+
+	for _, typeName := range []string{OSArchBinDistTypeName, DebDistTypeName, ApkDistTypeName, ArchlinuxDistTypeName} {
+		dister, err := factory.NewDister(typeName, "")
+		require.NoError(t, err, "type %q", typeName)
+		gotTypeName, err := dister.TypeName()
+		require.NoError(t, err, "type %q", typeName)
+		assert.Equal(t, typeName, gotTypeName)
+	}
+
+	_, err = factory.NewDister("not-a-real-type", "")
+	assert.Error(t, err)
+}
+
+func TestStageFiles(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	require.NoError(t, ioutil.WriteFile(path.Join(tmp, "foo"), []byte("foo-contents"), 0755))
+	require.NoError(t, ioutil.WriteFile(path.Join(tmp, "foo.txt"), []byte("docs"), 0644))
+
+	staged, err := stageFiles(tmp, []fileMapping{
+		{Src: "foo", Dst: "/usr/bin/foo", Mode: 0755},
+		{Src: "foo.txt", Dst: "/usr/share/doc/foo/README", Mode: 0644},
+	}, []symlink{
+		{Link: "/usr/bin/foo-alias", Target: "/usr/bin/foo"},
+	})
+	require.NoError(t, err)
+	require.Len(t, staged, 3)
+
+	// staged entries are sorted by destination so that packaging output is reproducible.
+	assert.Equal(t, "/usr/bin/foo", staged[0].dst)
+	assert.Equal(t, "/usr/bin/foo-alias", staged[1].dst)
+	assert.Equal(t, "/usr/share/doc/foo/README", staged[2].dst)
+	assert.Equal(t, "/usr/bin/foo", staged[1].linkDest)
+
+	_, err = stageFiles(tmp, []fileMapping{{Src: "does-not-exist", Dst: "/nope"}}, nil)
+	assert.Error(t, err)
+}
+
+func TestStageFilesJoinsDstForMultiMatchGlob(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	require.NoError(t, ioutil.WriteFile(path.Join(tmp, "foo"), []byte("foo"), 0755))
+	require.NoError(t, ioutil.WriteFile(path.Join(tmp, "bar"), []byte("bar"), 0755))
+
+	staged, err := stageFiles(tmp, []fileMapping{{Src: "*", Dst: "/usr/bin/"}}, nil)
+	require.NoError(t, err)
+	require.Len(t, staged, 2)
+
+	var dsts []string
+	for _, s := range staged {
+		dsts = append(dsts, s.dst)
+	}
+	assert.ElementsMatch(t, []string{"/usr/bin/bar", "/usr/bin/foo"}, dsts)
+
+	_, err = stageFiles(tmp, []fileMapping{{Src: "*", Dst: "/usr/bin/app"}}, nil)
+	assert.Error(t, err, "a multi-match glob with a non-directory Dst should be rejected")
+}
+
+func TestArtifactNamesFollowProductVersionArchConvention(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		format singleArchFormat
+		want   string
+	}{
+		{"deb", newDebDister(DebConfig{Architecture: "amd64"}), "foo-1.0.0-amd64.deb"},
+		{"apk", newApkDister(ApkConfig{Architecture: "x86_64"}), "foo-1.0.0-x86_64.apk"},
+		{"archlinux", newArchlinuxDister(ArchlinuxConfig{Architecture: "x86_64"}), "foo-1.0.0-x86_64.pkg.tar.gz"},
+	} {
+		assert.Equal(t, tc.want, tc.format.ArtifactName("foo", "1.0.0"), tc.name)
+	}
+}
+
+func TestPickBuildOutputDir(t *testing.T) {
+	dir, err := pickBuildOutputDir(map[string]string{"linux-amd64": "/out/linux-amd64"}, "amd64")
+	require.NoError(t, err)
+	assert.Equal(t, "/out/linux-amd64", dir)
+
+	// multiple build output dirs, but the configured architecture disambiguates which one to use.
+	dir, err = pickBuildOutputDir(map[string]string{
+		"linux-amd64":  "/out/linux-amd64",
+		"darwin-arm64": "/out/darwin-arm64",
+	}, "arm64")
+	require.NoError(t, err)
+	assert.Equal(t, "/out/darwin-arm64", dir)
+
+	// multiple build output dirs and no configured architecture matches any of them.
+	_, err = pickBuildOutputDir(map[string]string{
+		"linux-amd64":  "/out/linux-amd64",
+		"darwin-amd64": "/out/darwin-amd64",
+	}, "arm64")
+	assert.Error(t, err)
+
+	// multiple build output dirs where the configured architecture matches more than one (e.g.
+	// "linux-amd64" and "darwin-amd64" both end in "-amd64").
+	_, err = pickBuildOutputDir(map[string]string{
+		"linux-amd64":  "/out/linux-amd64",
+		"darwin-amd64": "/out/darwin-amd64",
+	}, "amd64")
+	assert.Error(t, err)
+
+	_, err = pickBuildOutputDir(map[string]string{}, "amd64")
+	assert.Error(t, err)
+}
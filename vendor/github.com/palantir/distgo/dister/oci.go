@@ -0,0 +1,140 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dister
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/palantir/distgo/distgo"
+	"github.com/palantir/distgo/dockerbuilder/ocilayout"
+)
+
+// OCIDistTypeName is the name of the dister that produces a spec-compliant OCI image layout
+// directly on disk, without shelling out to "docker build".
+const OCIDistTypeName = "oci"
+
+// OCIConfig is the YAML-serializable configuration for the oci dister.
+type OCIConfig struct {
+	// BaseImage is an optional "host/name:tag" reference that is fetched via the registry v2
+	// protocol and whose layers are prepended to the product's own layer.
+	BaseImage string `yaml:"baseImage,omitempty" json:"baseImage,omitempty"`
+
+	Files  []fileMapping     `yaml:"files,omitempty" json:"files,omitempty"`
+	Env    []string          `yaml:"env,omitempty" json:"env,omitempty"`
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+
+	Entrypoint []string `yaml:"entrypoint,omitempty" json:"entrypoint,omitempty"`
+	Cmd        []string `yaml:"cmd,omitempty" json:"cmd,omitempty"`
+	WorkingDir string   `yaml:"workingDir,omitempty" json:"workingDir,omitempty"`
+	User       string   `yaml:"user,omitempty" json:"user,omitempty"`
+}
+
+// ociDister builds a spec-compliant OCI image layout under "<distDir>/<artifactName>/", with one
+// manifest per os/arch build output, reusing any configured base image as parent layers.
+type ociDister struct {
+	cfg OCIConfig
+}
+
+func newOCIDisterFromConfig(cfgYAML string) (distgo.Dister, error) {
+	var cfg OCIConfig
+	if err := unmarshalDisterConfig(cfgYAML, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal %s config", OCIDistTypeName)
+	}
+	return &ociDister{cfg: cfg}, nil
+}
+
+// TypeName returns the registered name of this dister.
+func (d *ociDister) TypeName() (string, error) {
+	return OCIDistTypeName, nil
+}
+
+// Artifacts returns the name of the directory that the OCI layout is written into.
+func (d *ociDister) Artifacts(productName, version string) ([]string, error) {
+	return []string{d.layoutDirName(productName, version)}, nil
+}
+
+func (d *ociDister) layoutDirName(productName, version string) string {
+	return fmt.Sprintf("%s-%s-oci", productName, version)
+}
+
+// Dist builds the OCI layout into distDir and returns the layout directory name.
+func (d *ociDister) Dist(productName, version string, buildOutputDirs map[string]string, distDir string) ([]string, error) {
+	layoutDir := filepath.Join(distDir, d.layoutDirName(productName, version))
+	layout, err := ocilayout.New(layoutDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var baseRef ocilayout.Ref
+	var reg *ocilayout.RegistryClient
+	if d.cfg.BaseImage != "" {
+		reg = ocilayout.NewRegistryClient()
+		baseRef, err = ocilayout.ParseRef(d.cfg.BaseImage)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var manifestDescs []ocilayout.Descriptor
+	for _, osArch := range ocilayout.SortedOSArches(buildOutputDirs) {
+		platformOS, platformArch, err := ocilayout.SplitOSArch(osArch)
+		if err != nil {
+			return nil, err
+		}
+
+		staged, err := stageFiles(buildOutputDirs[osArch], d.cfg.Files, nil)
+		if err != nil {
+			return nil, err
+		}
+		var layerFiles []ocilayout.StagedFile
+		for _, f := range staged {
+			layerFiles = append(layerFiles, ocilayout.StagedFile{
+				Path:     strings.TrimPrefix(f.dst, "/"),
+				SrcPath:  f.srcPath,
+				LinkPath: f.linkDest,
+				Mode:     f.mode,
+			})
+		}
+
+		manifestDesc, err := ocilayout.BuildPlatformManifest(reg, layout, ocilayout.PlatformImage{
+			OS:           platformOS,
+			Architecture: platformArch,
+			BaseImage:    baseRef,
+			Files:        layerFiles,
+			Config: ocilayout.ImageConfigSpec{
+				Env:        d.cfg.Env,
+				Entrypoint: d.cfg.Entrypoint,
+				Cmd:        d.cfg.Cmd,
+				WorkingDir: d.cfg.WorkingDir,
+				User:       d.cfg.User,
+				Labels:     d.cfg.Labels,
+			},
+			CreatedBy: fmt.Sprintf("distgo oci dister: %s %s", productName, version),
+		})
+		if err != nil {
+			return nil, err
+		}
+		manifestDescs = append(manifestDescs, manifestDesc)
+	}
+
+	if err := layout.WriteIndex(manifestDescs); err != nil {
+		return nil, err
+	}
+	return []string{d.layoutDirName(productName, version)}, nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dister
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/palantir/distgo/distgo"
+)
+
+// osArchBinDister is the default dister: it archives the built binaries for each os/arch as a
+// ".tgz" named "<product>-<version>-<os>-<arch>.tgz".
+type osArchBinDister struct{}
+
+func newOSArchBinDisterFromConfig(_ string) (distgo.Dister, error) {
+	return &osArchBinDister{}, nil
+}
+
+// TypeName returns the registered name of this dister.
+func (d *osArchBinDister) TypeName() (string, error) {
+	return OSArchBinDistTypeName, nil
+}
+
+// Artifacts returns no names: the set of target os/archs is not known statically, so callers
+// that need the artifact names ahead of time should call Dist and use its return value instead.
+// Dist writes one ".tgz" archive per os/arch build output.
+func (d *osArchBinDister) Artifacts(productName, version string) ([]string, error) {
+	return nil, nil
+}
+
+// Dist archives each os/arch's build output directory as its own ".tgz".
+func (d *osArchBinDister) Dist(productName, version string, buildOutputDirs map[string]string, distDir string) ([]string, error) {
+	var artifacts []string
+	for osArch, buildOutputDir := range buildOutputDirs {
+		artifactName := fmt.Sprintf("%s-%s-%s.tgz", productName, version, osArch)
+		artifactPath := filepath.Join(distDir, artifactName)
+		if err := archiveDirAsTarGz(buildOutputDir, artifactPath); err != nil {
+			return nil, err
+		}
+		artifacts = append(artifacts, artifactName)
+	}
+	return artifacts, nil
+}
+
+func archiveDirAsTarGz(srcDir, destPath string) error {
+	out, err := os.Create(destPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create %q", destPath)
+	}
+	defer func() { _ = out.Close() }()
+
+	gw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gw)
+	err = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err = copyFile(tw, path)
+		return err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to archive %q", srcDir)
+	}
+	if err := tw.Close(); err != nil {
+		return errors.Wrapf(err, "failed to close %q", destPath)
+	}
+	return gw.Close()
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dister
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/palantir/distgo/distgo"
+	"github.com/palantir/distgo/plugin"
+)
+
+// disterPluginPrefix is the executable name prefix that identifies a dister plugin: a dister
+// plugin advertising type name "foo" is an executable named "godel-dister-foo".
+const disterPluginPrefix = "godel-dister-"
+
+// registerPlugins discovers dister plugins on $PATH (and plugin.DirEnvVar) and registers one
+// under each plugin's advertised name.
+func registerPlugins(factory *distgo.DisterFactory) error {
+	for _, candidate := range plugin.Discover(disterPluginPrefix) {
+		md, err := plugin.FetchMetadata(candidate.Path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load dister plugin %q", candidate.Path)
+		}
+		path := candidate.Path
+		typeName := md.Name
+		if err := factory.Register(typeName, func(cfgYAML string) (distgo.Dister, error) {
+			return &pluginDister{typeName: typeName, path: path, cfg: cfgYAML}, nil
+		}); err != nil {
+			return errors.Wrapf(err, "failed to register dister plugin %q", typeName)
+		}
+	}
+	return nil
+}
+
+// pluginDisterRunRequest is the JSON payload sent to a dister plugin's "run" subcommand.
+//
+// This does not yet carry the full distgo.ProjectInfo/distgo.ProductParam that describe a
+// project's products, dependencies and build/dist configuration: distgo/dist.Products (the
+// orchestrator that constructs them) does not thread either through to the Disters it calls, only
+// to the environment of a product's custom dist script. A plugin that needs information about a
+// dependency's build/dist output must read it from the DEP_PRODUCT_ID_*-prefixed environment
+// variables forwarded into its process environment below, the same contract documented for custom
+// dist scripts.
+type pluginDisterRunRequest struct {
+	ProductName     string            `json:"productName"`
+	Version         string            `json:"version"`
+	BuildOutputDirs map[string]string `json:"buildOutputDirs"`
+	DistDir         string            `json:"distDir"`
+	Config          string            `json:"config"`
+}
+
+// pluginDisterRunResult is the JSON result a dister plugin's "run" subcommand writes to stdout.
+type pluginDisterRunResult struct {
+	Artifacts []string `json:"artifacts"`
+}
+
+// pluginDister is a distgo.Dister backed by an external "godel-dister-<name>" executable.
+type pluginDister struct {
+	typeName string
+	path     string
+	cfg      string
+}
+
+// TypeName returns the name this plugin advertised in its metadata descriptor.
+func (d *pluginDister) TypeName() (string, error) {
+	return d.typeName, nil
+}
+
+// Artifacts is not supported for plugin-backed disters: the plugin protocol only defines
+// "metadata" and "run" subcommands, and a plugin's artifact names are only known as the result of
+// actually running it.
+func (d *pluginDister) Artifacts(productName, version string) ([]string, error) {
+	return nil, errors.Errorf("dister plugin %q does not support listing artifacts without running Dist", d.typeName)
+}
+
+// Dist invokes the plugin's "run" subcommand, passing productName/version/buildOutputDirs/distDir
+// as a JSON request on stdin and the same environment-variable contract a custom dist script
+// receives -- DIST_DIR/VERSION plus (forwarded from this process's own environment, so that
+// whatever set them for a custom dist script also reaches a plugin) any DEP_PRODUCT_ID_*-prefixed
+// variables describing dependent products' build/dist output -- and returns the artifact names
+// from the plugin's JSON result.
+func (d *pluginDister) Dist(productName, version string, buildOutputDirs map[string]string, distDir string) ([]string, error) {
+	req := pluginDisterRunRequest{
+		ProductName:     productName,
+		Version:         version,
+		BuildOutputDirs: buildOutputDirs,
+		DistDir:         distDir,
+		Config:          d.cfg,
+	}
+	env := append(os.Environ(), fmt.Sprintf("DIST_DIR=%s", distDir), fmt.Sprintf("VERSION=%s", version))
+	result, err := plugin.Run(d.path, req, env, ioutil.Discard)
+	if err != nil {
+		return nil, errors.Wrapf(err, "dister plugin %q failed", d.typeName)
+	}
+	var parsed pluginDisterRunResult
+	if err := json.Unmarshal(result, &parsed); err != nil {
+		return nil, errors.Wrapf(err, "dister plugin %q returned an invalid run result", d.typeName)
+	}
+	return parsed.Artifacts, nil
+}
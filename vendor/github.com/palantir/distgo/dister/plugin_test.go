@@ -0,0 +1,133 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dister
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palantir/distgo/plugin"
+)
+
+const fakeDisterPlugin = `#!/usr/bin/env bash
+set -euo pipefail
+case "$1" in
+  metadata)
+    echo '{"SchemaVersion":"0.1.0","Name":"acme","Version":"1.0.0"}'
+    ;;
+  run)
+    cat >/dev/null
+    echo '{"artifacts":["acme-artifact.tgz"]}'
+    ;;
+  *)
+    echo "unknown subcommand" >&2
+    exit 1
+    ;;
+esac
+`
+
+const failingDisterPlugin = `#!/usr/bin/env bash
+echo "boom" >&2
+exit 1
+`
+
+func stageFakeDisterPlugin(t *testing.T, dir, name, script string) {
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0755))
+}
+
+func TestNewDisterFactoryRegistersPlugins(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	stageFakeDisterPlugin(t, tmp, "godel-dister-acme", fakeDisterPlugin)
+
+	require.NoError(t, os.Setenv(plugin.DirEnvVar, tmp))
+	defer func() { _ = os.Unsetenv(plugin.DirEnvVar) }()
+
+	factory, err := NewDisterFactory()
+	require.NoError(t, err)
+
+	dister, err := factory.NewDister("acme", `{}`)
+	require.NoError(t, err)
+	typeName, err := dister.TypeName()
+	require.NoError(t, err)
+	assert.Equal(t, "acme", typeName)
+
+	buildOutputDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(buildOutputDir) }()
+	distDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(distDir) }()
+
+	artifacts, err := dister.Dist("foo", "1.2.3", map[string]string{"linux-amd64": buildOutputDir}, distDir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"acme-artifact.tgz"}, artifacts)
+
+	_, err = dister.Artifacts("foo", "1.2.3")
+	assert.Error(t, err, "plugin disters cannot report artifacts without running Dist")
+}
+
+func TestPluginDisterForwardsDepProductIDEnvVars(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	const envProbePlugin = `#!/usr/bin/env bash
+set -euo pipefail
+case "$1" in
+  metadata)
+    echo '{"SchemaVersion":"0.1.0","Name":"acme","Version":"1.0.0"}'
+    ;;
+  run)
+    cat >/dev/null
+    echo "{\"artifacts\":[\"$DEP_PRODUCT_ID_COUNT-$DEP_PRODUCT_ID_0\"]}"
+    ;;
+  *)
+    echo "unknown subcommand" >&2
+    exit 1
+    ;;
+esac
+`
+	stageFakeDisterPlugin(t, tmp, "godel-dister-acme", envProbePlugin)
+
+	require.NoError(t, os.Setenv("DEP_PRODUCT_ID_COUNT", "1"))
+	defer func() { _ = os.Unsetenv("DEP_PRODUCT_ID_COUNT") }()
+	require.NoError(t, os.Setenv("DEP_PRODUCT_ID_0", "bar"))
+	defer func() { _ = os.Unsetenv("DEP_PRODUCT_ID_0") }()
+
+	d := &pluginDister{typeName: "acme", path: filepath.Join(tmp, "godel-dister-acme")}
+	artifacts, err := d.Dist("foo", "1.2.3", map[string]string{}, tmp)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1-bar"}, artifacts)
+}
+
+func TestPluginDisterPropagatesRunFailure(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	d := &pluginDister{typeName: "broken", path: filepath.Join(tmp, "godel-dister-broken")}
+	stageFakeDisterPlugin(t, tmp, "godel-dister-broken", failingDisterPlugin)
+
+	_, err = d.Dist("foo", "1.2.3", map[string]string{}, tmp)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "broken")
+}
@@ -0,0 +1,42 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distgo
+
+// CrossBuildConfig configures running a product's build and custom dist script inside a
+// container when the product's target os/arch differs from the host's, so that a single host
+// architecture can transparently produce artifacts for every configured os/arch (see the
+// crossbuild package for the implementation that consumes this config).
+type CrossBuildConfig struct {
+	// Images maps an "os-arch" key (e.g. "linux-arm64", matching the keys used elsewhere for a
+	// product's build output directories) to the image that cross builds for that os/arch run in.
+	// An os/arch with no entry here (including the host's own os/arch) is built natively.
+	Images map[string]string `yaml:"images,omitempty" json:"images,omitempty"`
+	// Volumes lists additional "hostPath:containerPath" bind mounts made available to the cross
+	// build container, beyond the project directory (which is always mounted).
+	Volumes []string `yaml:"volumes,omitempty" json:"volumes,omitempty"`
+	// EnvPassthrough lists environment variable names whose values, if set in the host process
+	// environment, are forwarded into the cross build container.
+	EnvPassthrough []string `yaml:"envPassthrough,omitempty" json:"envPassthrough,omitempty"`
+	// CacheDir is a host directory (created if it does not already exist) mounted into the cross
+	// build container at the same path, so that toolchain caches can persist across runs.
+	CacheDir string `yaml:"cacheDir,omitempty" json:"cacheDir,omitempty"`
+}
+
+// NeedsCrossBuild reports whether osArch (an "os-arch" key, e.g. "linux-arm64") has a configured
+// cross-build image, returning that image if so.
+func (c CrossBuildConfig) NeedsCrossBuild(osArch string) (string, bool) {
+	image, ok := c.Images[osArch]
+	return image, ok
+}
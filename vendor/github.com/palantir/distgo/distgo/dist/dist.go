@@ -0,0 +1,274 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dist orchestrates building and dist'ing a project's products: for each product (built
+// in dependency order, so a product's dependencies are always built/dist'd before it), it builds
+// the product's main package, then runs every configured Dister (and its optional custom dist
+// script, with the DEP_PRODUCT_ID_*/DIST_DIR/VERSION environment contract documented on
+// dister/plugin.go) against that build output.
+package dist
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+
+	"github.com/palantir/godel/pkg/osarch"
+	"github.com/pkg/errors"
+
+	"github.com/palantir/distgo/distgo"
+)
+
+// distResult records where a single DistID's output for a product landed, so that a product
+// depending on it can be given that information via the DEP_PRODUCT_ID_* environment contract.
+type distResult struct {
+	distDir   string
+	artifacts []string
+}
+
+// productResult records a built/dist'd product's output, keyed for the same reason as distResult.
+type productResult struct {
+	buildDir string
+	dists    map[string]distResult
+}
+
+// Products builds and dists productIDs (every product in projectParam, if productIDs is empty) in
+// dependency order, writing build output to
+// "<projectDir>/out/build/<productID>/<version>/<os>-<arch>" and dist output to
+// "<projectDir>/out/dist/<productID>/<version>/<distID>", streaming build/dist script output to
+// w. If dryRun is true, the actions that would be taken are written to w but not performed.
+func Products(projectInfo distgo.ProjectInfo, projectParam distgo.ProjectParam, productIDs []distgo.ProductID, dryRun bool, w io.Writer) error {
+	order, err := buildOrder(projectParam, productIDs)
+	if err != nil {
+		return err
+	}
+
+	results := map[distgo.ProductID]productResult{}
+	for _, id := range order {
+		product := projectParam.Products[id]
+		if dryRun {
+			fmt.Fprintf(w, "[dry-run] would build and dist %s\n", id)
+			continue
+		}
+
+		buildDir, buildOutputDirs, err := buildProduct(projectInfo, product, w)
+		if err != nil {
+			return errors.Wrapf(err, "failed to build product %q", id)
+		}
+
+		dists, err := distProduct(projectInfo, projectParam.ScriptIncludes, product, buildOutputDirs, results, w)
+		if err != nil {
+			return errors.Wrapf(err, "failed to dist product %q", id)
+		}
+
+		results[id] = productResult{buildDir: buildDir, dists: dists}
+	}
+	return nil
+}
+
+// buildOrder returns the product IDs to process, in dependency order (a product always appears
+// after every product it (transitively) depends on), expanding productIDs to include their
+// transitive dependencies. An empty productIDs processes every product in projectParam.
+func buildOrder(projectParam distgo.ProjectParam, productIDs []distgo.ProductID) ([]distgo.ProductID, error) {
+	selected := productIDs
+	if len(selected) == 0 {
+		for id := range projectParam.Products {
+			selected = append(selected, id)
+		}
+	}
+
+	included := map[distgo.ProductID]bool{}
+	var include func(id distgo.ProductID) error
+	include = func(id distgo.ProductID) error {
+		if included[id] {
+			return nil
+		}
+		product, ok := projectParam.Products[id]
+		if !ok {
+			return errors.Errorf("unknown product %q", id)
+		}
+		included[id] = true
+		for _, dep := range product.Dependencies {
+			if err := include(dep); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, id := range selected {
+		if err := include(id); err != nil {
+			return nil, err
+		}
+	}
+
+	ids := make([]distgo.ProductID, 0, len(included))
+	for id := range included {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[distgo.ProductID]int{}
+	var order []distgo.ProductID
+	var visit func(id distgo.ProductID) error
+	visit = func(id distgo.ProductID) error {
+		switch state[id] {
+		case visited:
+			return nil
+		case visiting:
+			return errors.Errorf("circular dependency detected at product %q", id)
+		}
+		state[id] = visiting
+		for _, dep := range projectParam.Products[id].Dependencies {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[id] = visited
+		order = append(order, id)
+		return nil
+	}
+	for _, id := range ids {
+		if err := visit(id); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// buildProduct builds product's main package for the host's os/arch, returning the product's
+// top-level build directory (out/build/<id>/<version>, the same value surfaced to dependents as
+// DEP_PRODUCT_ID_*_BUILD_DIR) and the os/arch-keyed build output directory map that its Disters
+// consume.
+//
+// GO111MODULE=auto lets this build a target project that predates Go modules (no go.mod) via
+// legacy GOPATH-style resolution of product.Build.MainPkg's relative import path, while still
+// respecting a target project's own go.mod when one is present.
+func buildProduct(projectInfo distgo.ProjectInfo, product distgo.ProductParam, w io.Writer) (string, map[string]string, error) {
+	buildDir := filepath.Join(projectInfo.ProjectDir, "out", "build", string(product.ID), projectInfo.Version)
+	osArch := osarch.Current().String()
+	outputDir := filepath.Join(buildDir, osArch)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return "", nil, errors.Wrapf(err, "failed to create build output directory %q", outputDir)
+	}
+
+	binPath := filepath.Join(outputDir, string(product.ID))
+	cmd := exec.Command("go", "build", "-o", binPath, product.Build.MainPkg)
+	cmd.Dir = projectInfo.ProjectDir
+	cmd.Env = append(os.Environ(), "GO111MODULE=auto")
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Run(); err != nil {
+		return "", nil, errors.Wrapf(err, "failed to build product %q (main package %q)", product.ID, product.Build.MainPkg)
+	}
+
+	return buildDir, map[string]string{osArch: outputDir}, nil
+}
+
+// distProduct runs every one of product's configured Disters (in DistID order, for determinism)
+// against buildOutputDirs, followed by that Dister's custom script (if any) with the
+// DEP_PRODUCT_ID_*/DIST_DIR/VERSION environment contract populated from depResults.
+func distProduct(projectInfo distgo.ProjectInfo, scriptIncludes string, product distgo.ProductParam, buildOutputDirs map[string]string, depResults map[distgo.ProductID]productResult, w io.Writer) (map[string]distResult, error) {
+	distIDs := make([]string, 0, len(product.Dist.Disters))
+	for distID := range product.Dist.Disters {
+		distIDs = append(distIDs, distID)
+	}
+	sort.Strings(distIDs)
+
+	results := make(map[string]distResult, len(distIDs))
+	for _, distID := range distIDs {
+		disterParam := product.Dist.Disters[distID]
+
+		distDir := filepath.Join(projectInfo.ProjectDir, "out", "dist", string(product.ID), projectInfo.Version, distID)
+		if err := os.MkdirAll(distDir, 0755); err != nil {
+			return nil, errors.Wrapf(err, "failed to create dist output directory %q", distDir)
+		}
+
+		artifacts, err := disterParam.Dister.Dist(string(product.ID), projectInfo.Version, buildOutputDirs, distDir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "dister failed for dist %q", distID)
+		}
+
+		if disterParam.Script != nil {
+			env := scriptEnv(projectInfo, distDir, product, depResults)
+			if err := runScript(scriptIncludes, *disterParam.Script, distDir, env, w); err != nil {
+				return nil, errors.Wrapf(err, "custom dist script failed for dist %q", distID)
+			}
+		}
+
+		results[distID] = distResult{distDir: distDir, artifacts: artifacts}
+	}
+	return results, nil
+}
+
+// scriptEnv returns the process environment a product's custom dist script runs with: the host
+// environment plus DIST_DIR/VERSION for the dist currently running and, for each of the product's
+// configured dependencies, DEP_PRODUCT_ID_<i> (that dependency's ID), DEP_PRODUCT_ID_<i>_BUILD_DIR
+// and, per dependency dist (in DistID order), DEP_PRODUCT_ID_<i>_DIST_ID_<j>,
+// DEP_PRODUCT_ID_<i>_DIST_ID_<j>_DIST_DIR and DEP_PRODUCT_ID_<i>_DIST_ID_<j>_DIST_ARTIFACT_<k> --
+// see dister/plugin.go's doc comment for this same contract.
+func scriptEnv(projectInfo distgo.ProjectInfo, distDir string, product distgo.ProductParam, depResults map[distgo.ProductID]productResult) []string {
+	env := append(os.Environ(),
+		fmt.Sprintf("DIST_DIR=%s", distDir),
+		fmt.Sprintf("VERSION=%s", projectInfo.Version),
+		fmt.Sprintf("DEP_PRODUCT_ID_COUNT=%d", len(product.Dependencies)),
+	)
+	for i, depID := range product.Dependencies {
+		dep := depResults[depID]
+		env = append(env,
+			fmt.Sprintf("DEP_PRODUCT_ID_%d=%s", i, depID),
+			fmt.Sprintf("DEP_PRODUCT_ID_%d_BUILD_DIR=%s", i, dep.buildDir),
+		)
+
+		distIDs := make([]string, 0, len(dep.dists))
+		for distID := range dep.dists {
+			distIDs = append(distIDs, distID)
+		}
+		sort.Strings(distIDs)
+		for j, distID := range distIDs {
+			d := dep.dists[distID]
+			env = append(env,
+				fmt.Sprintf("DEP_PRODUCT_ID_%d_DIST_ID_%d=%s", i, j, distID),
+				fmt.Sprintf("DEP_PRODUCT_ID_%d_DIST_ID_%d_DIST_DIR=%s", i, j, d.distDir),
+			)
+			for k, artifact := range d.artifacts {
+				env = append(env, fmt.Sprintf("DEP_PRODUCT_ID_%d_DIST_ID_%d_DIST_ARTIFACT_%d=%s", i, j, k, artifact))
+			}
+		}
+	}
+	return env
+}
+
+// runScript runs script (preceded by scriptIncludes, if any) via "bash -c" in dir with env as its
+// process environment. script's own "#!/usr/bin/env bash" shebang line (if present) is interpreted
+// as a harmless comment, since it isn't the first byte of an executed file here.
+func runScript(scriptIncludes, script, dir string, env []string, w io.Writer) error {
+	combined := script
+	if scriptIncludes != "" {
+		combined = scriptIncludes + "\n" + script
+	}
+	cmd := exec.Command("/bin/bash", "-c", combined)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = w
+	cmd.Stderr = w
+	return cmd.Run()
+}
@@ -0,0 +1,31 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dist_test
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMain sets GO111MODULE=auto for the test binary's process environment (inherited by the "go
+// build"/"go list" subprocesses that dist.Products and gofiles.Write shell out to) so that the
+// go-module-less temporary projects TestDist creates resolve via legacy GOPATH-style package
+// lookup instead of failing with "cannot find main module".
+func TestMain(m *testing.M) {
+	if err := os.Setenv("GO111MODULE", "auto"); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
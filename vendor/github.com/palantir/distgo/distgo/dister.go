@@ -0,0 +1,91 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distgo
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Dister is the interface implemented by the individual dist type implementations (os-arch-bin,
+// deb, apk, archlinux, oci, etc.). A Dister is responsible for producing one or more artifacts in
+// the output directory assigned to a single DistID for a product.
+type Dister interface {
+	// TypeName returns the name that this Dister was registered under.
+	TypeName() (string, error)
+
+	// Artifacts returns the names of the artifacts that Dist produces for the given product and
+	// version, relative to the DistID's output directory.
+	Artifacts(productName, version string) ([]string, error)
+
+	// Dist builds the output for this Dister into distDir using the product's build output
+	// directories (keyed by os/arch) as input, and returns the paths (relative to distDir) of the
+	// artifacts that were produced.
+	Dist(productName, version string, buildOutputDirs map[string]string, distDir string) ([]string, error)
+}
+
+// DisterConfig is the raw (YAML-serializable) configuration for a single Dister: the registered
+// type name plus its type-specific configuration payload, and an optional custom shell script
+// that is run (with the dist environment-variable contract) after the Dister completes.
+type DisterConfig struct {
+	Type   string  `yaml:"type,omitempty" json:"type,omitempty"`
+	Config string  `yaml:"config,omitempty" json:"config,omitempty"`
+	Script *string `yaml:"script,omitempty" json:"script,omitempty"`
+}
+
+// DistersConfig is the map of DistID to DisterConfig configured for a product's "dist" block.
+type DistersConfig map[string]DisterConfig
+
+// DisterCreator creates a Dister from its raw (YAML) configuration payload.
+type DisterCreator func(cfgYAML string) (Dister, error)
+
+// DisterFactory creates Disters from their registered type name and raw configuration.
+type DisterFactory struct {
+	creators map[string]DisterCreator
+}
+
+// NewDisterFactory returns a DisterFactory with no registered types.
+func NewDisterFactory() (*DisterFactory, error) {
+	return &DisterFactory{creators: map[string]DisterCreator{}}, nil
+}
+
+// Register adds creator under typeName. It is an error to register the same type name twice.
+func (f *DisterFactory) Register(typeName string, creator DisterCreator) error {
+	if _, ok := f.creators[typeName]; ok {
+		return errors.Errorf("dister type %q is already registered", typeName)
+	}
+	f.creators[typeName] = creator
+	return nil
+}
+
+// NewDister creates the Dister registered under typeName using cfgYAML as its configuration.
+func (f *DisterFactory) NewDister(typeName, cfgYAML string) (Dister, error) {
+	creator, ok := f.creators[typeName]
+	if !ok {
+		return nil, errors.Errorf("no dister registered for type %q", typeName)
+	}
+	return creator(cfgYAML)
+}
+
+// Types returns the sorted names of the types registered with this factory.
+func (f *DisterFactory) Types() []string {
+	names := make([]string, 0, len(f.creators))
+	for name := range f.creators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,37 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distgo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palantir/distgo/distgo"
+)
+
+func TestDisterFactoryTypesIsSorted(t *testing.T) {
+	factory, err := distgo.NewDisterFactory()
+	require.NoError(t, err)
+
+	for _, typeName := range []string{"rpm", "apk", "deb", "archlinux"} {
+		require.NoError(t, factory.Register(typeName, func(cfgYAML string) (distgo.Dister, error) {
+			return nil, nil
+		}))
+	}
+
+	assert.Equal(t, []string{"apk", "archlinux", "deb", "rpm"}, factory.Types())
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distgo
+
+import (
+	"io"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// DockerBuilder is the interface implemented by the individual docker/OCI image builder
+// implementations (the default Dockerfile-driven builder, the daemonless OCI builder, etc.).
+type DockerBuilder interface {
+	// TypeName returns the name that this DockerBuilder was registered under.
+	TypeName() (string, error)
+
+	// RunDockerBuild builds the image(s) described by this DockerBuilder's configuration (the
+	// payload it was created from) for the given product using
+	// buildOutputDirs (the product's build output directories, keyed by os/arch) as input, and
+	// streams build logs to w.
+	RunDockerBuild(productName, version string, buildOutputDirs map[string]string, contextDir string, w io.Writer) error
+}
+
+// DockerBuilderConfig is the raw (YAML-serializable) configuration for a single DockerBuilder:
+// the registered type name plus its type-specific configuration payload.
+type DockerBuilderConfig struct {
+	Type   string `yaml:"type,omitempty" json:"type,omitempty"`
+	Config string `yaml:"config,omitempty" json:"config,omitempty"`
+}
+
+// DockerBuilderCreator creates a DockerBuilder from its raw (YAML) configuration payload.
+type DockerBuilderCreator func(cfgYAML string) (DockerBuilder, error)
+
+// DockerBuilderFactory creates DockerBuilders from their registered type name and raw
+// configuration.
+type DockerBuilderFactory struct {
+	creators map[string]DockerBuilderCreator
+}
+
+// NewDockerBuilderFactory returns a DockerBuilderFactory with no registered types.
+func NewDockerBuilderFactory() (*DockerBuilderFactory, error) {
+	return &DockerBuilderFactory{creators: map[string]DockerBuilderCreator{}}, nil
+}
+
+// Register adds creator under typeName. It is an error to register the same type name twice.
+func (f *DockerBuilderFactory) Register(typeName string, creator DockerBuilderCreator) error {
+	if _, ok := f.creators[typeName]; ok {
+		return errors.Errorf("docker builder type %q is already registered", typeName)
+	}
+	f.creators[typeName] = creator
+	return nil
+}
+
+// NewDockerBuilder creates the DockerBuilder registered under typeName using cfgYAML as its
+// configuration.
+func (f *DockerBuilderFactory) NewDockerBuilder(typeName, cfgYAML string) (DockerBuilder, error) {
+	creator, ok := f.creators[typeName]
+	if !ok {
+		return nil, errors.Errorf("no docker builder registered for type %q", typeName)
+	}
+	return creator(cfgYAML)
+}
+
+// Types returns the sorted names of the types registered with this factory.
+func (f *DockerBuilderFactory) Types() []string {
+	names := make([]string, 0, len(f.creators))
+	for name := range f.creators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,289 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package distgo defines the configuration and parameter types that describe a project's
+// products (ProjectConfig/ProjectParam) and the Dister/DockerBuilder plugin interfaces that
+// build/dist implementations (the dister, dockerbuilder and distgo/dist packages) are written
+// against.
+package distgo
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ProductID identifies a single product (a buildable, distributable unit) within a project.
+type ProductID string
+
+// BuildConfig is the raw (YAML-serializable) configuration for how a product is built.
+type BuildConfig struct {
+	// MainPkg is the package (relative to the project root, e.g. "./foo") built as the product's
+	// entry point. Defaults to "./<productID>" if unset.
+	MainPkg *string `yaml:"main-pkg,omitempty" json:"main-pkg,omitempty"`
+}
+
+// DistConfig is the raw (YAML-serializable) configuration for how a product is distributed.
+type DistConfig struct {
+	// Disters maps DistID to the DisterConfig that produces that dist's artifacts. Defaults to a
+	// single dist (keyed by the default dister's type name) using the default Dister, os-arch-bin,
+	// if unset.
+	Disters *DistersConfig `yaml:"disters,omitempty" json:"disters,omitempty"`
+}
+
+// ProductConfig is the raw (YAML-serializable) configuration for a single product: how it is
+// built, how it is distributed, and which other products (by ID) it depends on. A nil field
+// defers to the ProjectConfig's ProductDefaults, and ultimately to the type-specific default
+// described on BuildConfig/DistConfig.
+type ProductConfig struct {
+	Build        *BuildConfig `yaml:"build,omitempty" json:"build,omitempty"`
+	Dist         *DistConfig  `yaml:"dist,omitempty" json:"dist,omitempty"`
+	Dependencies *[]ProductID `yaml:"dependencies,omitempty" json:"dependencies,omitempty"`
+}
+
+// ProjectConfig is the root, YAML-serializable configuration for a distgo project.
+type ProjectConfig struct {
+	// Products is the set of products in the project, keyed by ID. If empty, ToParam discovers
+	// one product per top-level project directory that contains a Go "package main" file, using
+	// the directory name as both the product ID and its main package.
+	Products map[ProductID]ProductConfig `yaml:"products,omitempty" json:"products,omitempty"`
+	// ProductDefaults is applied to every product (explicitly configured or discovered) whose own
+	// configuration leaves a field unset.
+	ProductDefaults ProductConfig `yaml:"product-defaults,omitempty" json:"product-defaults,omitempty"`
+	// ScriptIncludes is shell source (function definitions, variables, etc.) made available to
+	// every product's custom dist script, included ahead of it. It has no effect for a product
+	// whose dist configuration does not specify a custom script.
+	ScriptIncludes string `yaml:"script-includes,omitempty" json:"script-includes,omitempty"`
+}
+
+// ProjectParam is the resolved form of a ProjectConfig: defaults applied, and every dister
+// referenced anywhere in the config instantiated via a DisterFactory. This is what dist.Products
+// consumes.
+type ProjectParam struct {
+	Products       map[ProductID]ProductParam
+	ScriptIncludes string
+}
+
+// ProductParam is the resolved form of a ProductConfig.
+type ProductParam struct {
+	ID           ProductID
+	Build        BuildParam
+	Dist         DistParam
+	Dependencies []ProductID
+}
+
+// BuildParam is the resolved form of a BuildConfig.
+type BuildParam struct {
+	MainPkg string
+}
+
+// DistParam is the resolved form of a DistConfig: every configured DisterConfig instantiated as a
+// live Dister, keyed by DistID.
+type DistParam struct {
+	Disters map[string]DisterParam
+}
+
+// DisterParam pairs an instantiated Dister with the optional custom dist script configured
+// alongside it.
+type DisterParam struct {
+	Dister Dister
+	Script *string
+}
+
+// ProjectInfo captures the project-wide information (as opposed to per-product parameters) that a
+// dist run needs: where the project lives and the version every product is dist'd under.
+type ProjectInfo struct {
+	ProjectDir string
+	Version    string
+}
+
+// ProjectInfo returns the ProjectInfo for projectDir, deriving Version from "git describe" so that
+// every product in a single dist run is versioned consistently off of the same commit.
+func (p ProjectParam) ProjectInfo(projectDir string) (ProjectInfo, error) {
+	version, err := gitDescribe(projectDir)
+	if err != nil {
+		return ProjectInfo{}, err
+	}
+	return ProjectInfo{
+		ProjectDir: projectDir,
+		Version:    version,
+	}, nil
+}
+
+// ToParam resolves cfg into a ProjectParam: it applies ProductDefaults to every product (falling
+// back further to the MainPkg/Disters defaults described on BuildConfig/DistConfig), then uses
+// disterFactory to instantiate every dister referenced anywhere in the result, substituting
+// defaultDistCfg for any product that leaves its dist configuration unset entirely.
+//
+// dockerBuilderFactory is accepted so that product Docker image configuration has a wiring point
+// once ProductConfig grows one; no product configuration in this tree produces a DockerBuilder
+// yet, so it is otherwise unused here.
+func (c ProjectConfig) ToParam(projectDir string, disterFactory *DisterFactory, defaultDistCfg DisterConfig, dockerBuilderFactory *DockerBuilderFactory) (ProjectParam, error) {
+	productCfgs := c.Products
+	if len(productCfgs) == 0 {
+		discovered, err := discoverProducts(projectDir)
+		if err != nil {
+			return ProjectParam{}, err
+		}
+		productCfgs = discovered
+	}
+
+	products := make(map[ProductID]ProductParam, len(productCfgs))
+	for id, cfg := range productCfgs {
+		merged := mergeProductConfig(c.ProductDefaults, cfg)
+		param, err := merged.toParam(id, disterFactory, defaultDistCfg)
+		if err != nil {
+			return ProjectParam{}, errors.Wrapf(err, "failed to resolve product %q", id)
+		}
+		products[id] = param
+	}
+	return ProjectParam{
+		Products:       products,
+		ScriptIncludes: c.ScriptIncludes,
+	}, nil
+}
+
+// mergeProductConfig returns override with any field it leaves unset (Build, Dist, Dependencies)
+// filled in from defaults.
+func mergeProductConfig(defaults, override ProductConfig) ProductConfig {
+	merged := override
+	if merged.Build == nil {
+		merged.Build = defaults.Build
+	}
+	if merged.Dist == nil {
+		merged.Dist = defaults.Dist
+	}
+	if merged.Dependencies == nil {
+		merged.Dependencies = defaults.Dependencies
+	}
+	return merged
+}
+
+// toParam resolves a single (already-defaulted) ProductConfig into a ProductParam.
+func (c ProductConfig) toParam(id ProductID, disterFactory *DisterFactory, defaultDistCfg DisterConfig) (ProductParam, error) {
+	mainPkg := "./" + string(id)
+	if c.Build != nil && c.Build.MainPkg != nil {
+		mainPkg = normalizeMainPkg(*c.Build.MainPkg)
+	}
+
+	distersCfg := DistersConfig{defaultDistCfg.Type: defaultDistCfg}
+	if c.Dist != nil && c.Dist.Disters != nil {
+		distersCfg = *c.Dist.Disters
+	}
+
+	disters := make(map[string]DisterParam, len(distersCfg))
+	for distID, disterCfg := range distersCfg {
+		d, err := disterFactory.NewDister(disterCfg.Type, disterCfg.Config)
+		if err != nil {
+			return ProductParam{}, errors.Wrapf(err, "failed to create dister %q for dist %q", disterCfg.Type, distID)
+		}
+		disters[distID] = DisterParam{Dister: d, Script: disterCfg.Script}
+	}
+
+	var deps []ProductID
+	if c.Dependencies != nil {
+		deps = *c.Dependencies
+	}
+
+	return ProductParam{
+		ID:           id,
+		Build:        BuildParam{MainPkg: mainPkg},
+		Dist:         DistParam{Disters: disters},
+		Dependencies: deps,
+	}, nil
+}
+
+// normalizeMainPkg rewrites a configured MainPkg that names a project-relative directory (e.g.
+// "foo") into the "./foo" form "go build" requires to resolve it as a local package rather than an
+// import path; a MainPkg that is already relative ("./foo") or absolute is left as-is.
+func normalizeMainPkg(mainPkg string) string {
+	if strings.HasPrefix(mainPkg, ".") || strings.HasPrefix(mainPkg, "/") {
+		return mainPkg
+	}
+	return "./" + mainPkg
+}
+
+// discoverProducts scans projectDir's immediate subdirectories for one containing a Go
+// "package main" file and returns one ProductConfig per match, keyed by (and with MainPkg set to)
+// the directory name. Used by ToParam when a ProjectConfig declares no products explicitly, so
+// that a project with no distgo configuration at all can still be dist'd.
+func discoverProducts(projectDir string) (map[ProductID]ProductConfig, error) {
+	entries, err := ioutil.ReadDir(projectDir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read project directory %q", projectDir)
+	}
+	products := map[ProductID]ProductConfig{}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		dir := filepath.Join(projectDir, entry.Name())
+		isMain, err := dirHasMainPackage(dir)
+		if err != nil {
+			return nil, err
+		}
+		if !isMain {
+			continue
+		}
+		mainPkg := "./" + entry.Name()
+		products[ProductID(entry.Name())] = ProductConfig{
+			Build: &BuildConfig{MainPkg: &mainPkg},
+		}
+	}
+	return products, nil
+}
+
+// dirHasMainPackage reports whether dir directly contains a ".go" file declaring "package main".
+func dirHasMainPackage(dir string) (bool, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to read directory %q", dir)
+	}
+	fset := token.NewFileSet()
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".go") {
+			continue
+		}
+		parsed, err := parser.ParseFile(fset, filepath.Join(dir, f.Name()), nil, parser.PackageClauseOnly)
+		if err != nil {
+			continue
+		}
+		if pkgNameIsMain(parsed) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func pkgNameIsMain(f *ast.File) bool {
+	return f.Name != nil && f.Name.Name == "main"
+}
+
+// gitDescribe returns the output of "git describe --tags --always" run against projectDir,
+// trimmed of surrounding whitespace, as the version for the current commit.
+func gitDescribe(projectDir string) (string, error) {
+	cmd := exec.Command("git", "describe", "--tags", "--always")
+	cmd.Dir = projectDir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to determine version for %q via git describe", projectDir)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
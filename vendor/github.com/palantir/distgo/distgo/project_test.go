@@ -0,0 +1,93 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distgo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscoverProductsFindsOnlyMainPackageDirs(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(tmp, "foo"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmp, "foo", "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(tmp, "somelib"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmp, "somelib", "lib.go"), []byte("package somelib\n"), 0644))
+
+	products, err := discoverProducts(tmp)
+	require.NoError(t, err)
+
+	require.Contains(t, products, ProductID("foo"))
+	assert.Equal(t, "./foo", *products["foo"].Build.MainPkg)
+	assert.NotContains(t, products, ProductID("somelib"))
+}
+
+func TestNormalizeMainPkg(t *testing.T) {
+	assert.Equal(t, "./foo", normalizeMainPkg("foo"))
+	assert.Equal(t, "./foo", normalizeMainPkg("./foo"))
+	assert.Equal(t, "/abs/foo", normalizeMainPkg("/abs/foo"))
+}
+
+func TestMergeProductConfig(t *testing.T) {
+	mainPkg := "./explicit"
+	defaults := ProductConfig{
+		Build: &BuildConfig{MainPkg: &mainPkg},
+	}
+	override := ProductConfig{}
+
+	merged := mergeProductConfig(defaults, override)
+	require.NotNil(t, merged.Build)
+	assert.Equal(t, &mainPkg, merged.Build.MainPkg)
+
+	overrideMainPkg := "./override"
+	override = ProductConfig{Build: &BuildConfig{MainPkg: &overrideMainPkg}}
+	merged = mergeProductConfig(defaults, override)
+	assert.Equal(t, &overrideMainPkg, merged.Build.MainPkg)
+}
+
+func TestProjectConfigToParamDefaultsDistToDefaultDisterConfig(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(tmp, "foo"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmp, "foo", "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644))
+
+	disterFactory, err := NewDisterFactory()
+	require.NoError(t, err)
+	require.NoError(t, disterFactory.Register("os-arch-bin", func(cfgYAML string) (Dister, error) {
+		return nil, nil
+	}))
+	defaultDistCfg := DisterConfig{Type: "os-arch-bin", Config: "{}"}
+	dockerBuilderFactory, err := NewDockerBuilderFactory()
+	require.NoError(t, err)
+
+	param, err := ProjectConfig{}.ToParam(tmp, disterFactory, defaultDistCfg, dockerBuilderFactory)
+	require.NoError(t, err)
+
+	require.Contains(t, param.Products, ProductID("foo"))
+	foo := param.Products["foo"]
+	assert.Equal(t, "./foo", foo.Build.MainPkg)
+	require.Contains(t, foo.Dist.Disters, "os-arch-bin")
+}
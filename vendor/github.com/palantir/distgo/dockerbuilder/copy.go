@@ -0,0 +1,216 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dockerbuilder
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/palantir/distgo/dockerbuilder/ocilayout"
+)
+
+// Transport identifies one side of a Copy operation, analogous to skopeo's "oci:", "dir:" and
+// "docker://" transport prefixes.
+type Transport int
+
+const (
+	// TransportOCI refers to an OCI image layout directory, addressed as "oci:<dir>:<tag>".
+	TransportOCI Transport = iota
+	// TransportDir refers to a plain directory of blobs/index.json, addressed as "dir:<dir>".
+	TransportDir
+	// TransportDocker refers to a registry image, addressed as "docker://<host>/<name>:<tag>".
+	TransportDocker
+)
+
+// ImageRef is a parsed copy source/destination reference.
+type ImageRef struct {
+	Transport Transport
+	Dir       string        // set for TransportOCI and TransportDir
+	Tag       string        // set for TransportOCI
+	Registry  ocilayout.Ref // set for TransportDocker
+}
+
+// ParseImageRef parses a "oci:<dir>:<tag>", "dir:<dir>" or "docker://<host>/<name>:<tag>"
+// reference, mirroring skopeo's copy source/destination syntax.
+func ParseImageRef(ref string) (ImageRef, error) {
+	switch {
+	case strings.HasPrefix(ref, "oci:"):
+		rest := strings.TrimPrefix(ref, "oci:")
+		parts := strings.SplitN(rest, ":", 2)
+		dir := parts[0]
+		tag := "latest"
+		if len(parts) == 2 {
+			tag = parts[1]
+		}
+		return ImageRef{Transport: TransportOCI, Dir: dir, Tag: tag}, nil
+	case strings.HasPrefix(ref, "dir:"):
+		return ImageRef{Transport: TransportDir, Dir: strings.TrimPrefix(ref, "dir:")}, nil
+	case strings.HasPrefix(ref, "docker://"):
+		registryRef, err := ocilayout.ParseRef(strings.TrimPrefix(ref, "docker://"))
+		if err != nil {
+			return ImageRef{}, err
+		}
+		return ImageRef{Transport: TransportDocker, Registry: registryRef}, nil
+	default:
+		return ImageRef{}, errors.Errorf(`unrecognized image reference %q: expected "oci:", "dir:" or "docker://" prefix`, ref)
+	}
+}
+
+// Copy copies the image(s) referenced by src to dst, across any combination of the oci:, dir:
+// and docker:// transports, analogous to skopeo's copy command.
+func Copy(src, dst ImageRef, insecure bool) error {
+	index, blobs, err := readSource(src, insecure)
+	if err != nil {
+		return errors.Wrap(err, "failed to read copy source")
+	}
+	if err := writeDestination(dst, index, blobs, insecure); err != nil {
+		return errors.Wrap(err, "failed to write copy destination")
+	}
+	return nil
+}
+
+// readSource reads src's index (synthesizing a single-manifest index for the docker:// and dir:
+// cases where one isn't already present) and returns every blob referenced by it, keyed by
+// digest.
+func readSource(src ImageRef, insecure bool) (ocilayout.Index, map[string][]byte, error) {
+	switch src.Transport {
+	case TransportOCI, TransportDir:
+		indexBytes, err := ioutil.ReadFile(filepath.Join(src.Dir, "index.json"))
+		if err != nil {
+			return ocilayout.Index{}, nil, err
+		}
+		var index ocilayout.Index
+		if err := json.Unmarshal(indexBytes, &index); err != nil {
+			return ocilayout.Index{}, nil, err
+		}
+		blobs := map[string][]byte{}
+		for _, m := range index.Manifests {
+			manifestBytes, err := ioutil.ReadFile(filepath.Join(src.Dir, "blobs", "sha256", ocilayout.DigestHex(m.Digest)))
+			if err != nil {
+				return ocilayout.Index{}, nil, err
+			}
+			blobs[m.Digest] = manifestBytes
+			var manifest ocilayout.Manifest
+			if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+				return ocilayout.Index{}, nil, err
+			}
+			descs := append([]ocilayout.Descriptor{manifest.Config}, manifest.Layers...)
+			for _, d := range descs {
+				content, err := ioutil.ReadFile(filepath.Join(src.Dir, "blobs", "sha256", ocilayout.DigestHex(d.Digest)))
+				if err != nil {
+					return ocilayout.Index{}, nil, err
+				}
+				blobs[d.Digest] = content
+			}
+		}
+		return index, blobs, nil
+	case TransportDocker:
+		client := ocilayout.NewRegistryClient()
+		client.Insecure = insecure
+		manifestBytes, mediaType, err := client.FetchManifest(src.Registry)
+		if err != nil {
+			return ocilayout.Index{}, nil, err
+		}
+		blobs := map[string][]byte{}
+		var manifest ocilayout.Manifest
+		if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+			return ocilayout.Index{}, nil, err
+		}
+		descs := append([]ocilayout.Descriptor{manifest.Config}, manifest.Layers...)
+		for _, d := range descs {
+			content, err := client.FetchBlob(src.Registry, d.Digest)
+			if err != nil {
+				return ocilayout.Index{}, nil, err
+			}
+			blobs[d.Digest] = content
+		}
+		sum := sha256.Sum256(manifestBytes)
+		manifestDigest := "sha256:" + hex.EncodeToString(sum[:])
+		blobs[manifestDigest] = manifestBytes
+		index := ocilayout.Index{
+			SchemaVersion: 2,
+			MediaType:     ocilayout.MediaTypeImageIndex,
+			Manifests: []ocilayout.Descriptor{
+				{MediaType: mediaType, Digest: manifestDigest, Size: int64(len(manifestBytes))},
+			},
+		}
+		return index, blobs, nil
+	default:
+		return ocilayout.Index{}, nil, errors.Errorf("unsupported source transport %v", src.Transport)
+	}
+}
+
+// writeDestination writes index/blobs to dst, pushing to a registry for the docker:// transport
+// or writing an on-disk layout for oci:/dir:.
+func writeDestination(dst ImageRef, index ocilayout.Index, blobs map[string][]byte, insecure bool) error {
+	switch dst.Transport {
+	case TransportOCI, TransportDir:
+		if err := os.MkdirAll(filepath.Join(dst.Dir, "blobs", "sha256"), 0755); err != nil {
+			return err
+		}
+		marker := `{"imageLayoutVersion":"1.0.0"}`
+		if err := ioutil.WriteFile(filepath.Join(dst.Dir, "oci-layout"), []byte(marker), 0644); err != nil {
+			return err
+		}
+		for digest, content := range blobs {
+			if err := ioutil.WriteFile(filepath.Join(dst.Dir, "blobs", "sha256", ocilayout.DigestHex(digest)), content, 0644); err != nil {
+				return err
+			}
+		}
+		indexBytes, err := json.MarshalIndent(index, "", "  ")
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dst.Dir, "index.json"), indexBytes, 0644)
+	case TransportDocker:
+		client := ocilayout.NewRegistryClient()
+		client.Insecure = insecure
+		for _, m := range index.Manifests {
+			manifestBytes := blobs[m.Digest]
+			var manifest ocilayout.Manifest
+			if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+				return err
+			}
+			descs := append([]ocilayout.Descriptor{manifest.Config}, manifest.Layers...)
+			for _, d := range descs {
+				if err := client.PushBlob(dst.Registry, d.Digest, blobs[d.Digest]); err != nil {
+					return err
+				}
+			}
+			if err := client.PushManifest(dst.Registry, manifest.MediaType, manifestBytes); err != nil {
+				return err
+			}
+		}
+		if len(index.Manifests) > 1 {
+			indexBytes, err := json.Marshal(index)
+			if err != nil {
+				return errors.Wrap(err, "failed to marshal index")
+			}
+			if err := client.PushManifest(dst.Registry, ocilayout.MediaTypeImageIndex, indexBytes); err != nil {
+				return errors.Wrap(err, "failed to push image index")
+			}
+		}
+		return nil
+	default:
+		return errors.Errorf("unsupported destination transport %v", dst.Transport)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dockerbuilder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImageRef(t *testing.T) {
+	ociRef, err := ParseImageRef("oci:/tmp/layout:1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, TransportOCI, ociRef.Transport)
+	assert.Equal(t, "/tmp/layout", ociRef.Dir)
+	assert.Equal(t, "1.0.0", ociRef.Tag)
+
+	dirRef, err := ParseImageRef("dir:/tmp/layout")
+	require.NoError(t, err)
+	assert.Equal(t, TransportDir, dirRef.Transport)
+	assert.Equal(t, "/tmp/layout", dirRef.Dir)
+
+	dockerRef, err := ParseImageRef("docker://registry.example.com/foo:1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, TransportDocker, dockerRef.Transport)
+	assert.Equal(t, "registry.example.com", dockerRef.Registry.Host)
+	assert.Equal(t, "foo", dockerRef.Registry.Name)
+	assert.Equal(t, "1.0.0", dockerRef.Registry.Tag)
+
+	_, err = ParseImageRef("not-a-valid-ref")
+	assert.Error(t, err)
+}
@@ -0,0 +1,188 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dockerbuilder
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/palantir/distgo/distgo"
+	"github.com/palantir/distgo/dockerbuilder/instructions"
+	"github.com/palantir/distgo/dockerbuilder/ocilayout"
+)
+
+// DaemonlessDockerBuilderTypeName is the name of the docker builder that produces an OCI image
+// layout directly on disk, without requiring a running Docker daemon.
+const DaemonlessDockerBuilderTypeName = "daemonless"
+
+// DaemonlessConfig is the YAML-serializable configuration for the daemonless docker builder.
+type DaemonlessConfig struct {
+	BaseImage  string                  `yaml:"baseImage,omitempty" json:"baseImage,omitempty"`
+	Files      []DaemonlessFileMapping `yaml:"files,omitempty" json:"files,omitempty"`
+	Env        []string                `yaml:"env,omitempty" json:"env,omitempty"`
+	Labels     map[string]string       `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Entrypoint []string                `yaml:"entrypoint,omitempty" json:"entrypoint,omitempty"`
+	Cmd        []string                `yaml:"cmd,omitempty" json:"cmd,omitempty"`
+	WorkingDir string                  `yaml:"workingDir,omitempty" json:"workingDir,omitempty"`
+	User       string                  `yaml:"user,omitempty" json:"user,omitempty"`
+	// Program is an alternative to Files/Env/Labels/Entrypoint/Cmd/WorkingDir/User: a typed build
+	// program applied directly to the layout being built. Only instructions.Copy and instructions
+	// that implement instructions.ConfigMutator are supported; Run and RunAll require a container
+	// runtime and are rejected.
+	Program []instructions.Step `yaml:"program,omitempty" json:"program,omitempty"`
+	// OutputDir is the path (relative to the product's dist directory) that the OCI layout is
+	// written into. Defaults to "oci".
+	OutputDir string `yaml:"outputDir,omitempty" json:"outputDir,omitempty"`
+}
+
+// DaemonlessFileMapping maps a source glob (resolved relative to the product's build output
+// directory) to a destination path inside the image.
+type DaemonlessFileMapping struct {
+	Src  string `yaml:"src" json:"src"`
+	Dst  string `yaml:"dst" json:"dst"`
+	Mode int64  `yaml:"mode,omitempty" json:"mode,omitempty"`
+}
+
+// daemonlessDockerBuilder builds an OCI image layout directly on disk from a product's build
+// outputs, removing the hard dependency on a running Docker daemon that the default builder has.
+type daemonlessDockerBuilder struct {
+	cfg DaemonlessConfig
+}
+
+func newDaemonlessDockerBuilderFromConfig(cfgYAML string) (distgo.DockerBuilder, error) {
+	var cfg DaemonlessConfig
+	if err := unmarshalDockerBuilderConfig(cfgYAML, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal %s config", DaemonlessDockerBuilderTypeName)
+	}
+	return &daemonlessDockerBuilder{cfg: cfg}, nil
+}
+
+// TypeName returns the registered name of this docker builder.
+func (b *daemonlessDockerBuilder) TypeName() (string, error) {
+	return DaemonlessDockerBuilderTypeName, nil
+}
+
+// RunDockerBuild builds an OCI layout under contextDir/<outputDir> from buildOutputDirs, fetching
+// the configured base image (if any) via the registry v2 protocol for use as parent layers.
+func (b *daemonlessDockerBuilder) RunDockerBuild(productName, version string, buildOutputDirs map[string]string, contextDir string, w io.Writer) error {
+	outputDir := b.cfg.OutputDir
+	if outputDir == "" {
+		outputDir = "oci"
+	}
+	layoutDir := filepath.Join(contextDir, outputDir)
+	fmt.Fprintf(w, "building OCI layout for %s at %s (daemonless)\n", productName, layoutDir)
+
+	layout, err := ocilayout.New(layoutDir)
+	if err != nil {
+		return err
+	}
+
+	var reg *ocilayout.RegistryClient
+	var baseRef ocilayout.Ref
+	if b.cfg.BaseImage != "" {
+		reg = ocilayout.NewRegistryClient()
+		baseRef, err = ocilayout.ParseRef(b.cfg.BaseImage)
+		if err != nil {
+			return err
+		}
+	}
+
+	var manifestDescs []ocilayout.Descriptor
+	for _, osArch := range ocilayout.SortedOSArches(buildOutputDirs) {
+		platformOS, platformArch, err := ocilayout.SplitOSArch(osArch)
+		if err != nil {
+			return err
+		}
+
+		buildOutputDir := buildOutputDirs[osArch]
+		var layerFiles []ocilayout.StagedFile
+		for _, m := range b.cfg.Files {
+			matches, err := filepath.Glob(filepath.Join(buildOutputDir, m.Src))
+			if err != nil {
+				return errors.Wrapf(err, "failed to resolve file mapping glob %q", m.Src)
+			}
+			if len(matches) == 0 {
+				return errors.Errorf("file mapping glob %q did not match any files in %s", m.Src, buildOutputDir)
+			}
+			for _, match := range matches {
+				layerFiles = append(layerFiles, ocilayout.StagedFile{
+					Path:    strings.TrimPrefix(m.Dst, "/"),
+					SrcPath: match,
+					Mode:    fileModeOrDefault(m.Mode),
+				})
+			}
+		}
+		configSpec := ocilayout.ImageConfigSpec{
+			Env:        b.cfg.Env,
+			Entrypoint: b.cfg.Entrypoint,
+			Cmd:        b.cfg.Cmd,
+			WorkingDir: b.cfg.WorkingDir,
+			User:       b.cfg.User,
+			Labels:     b.cfg.Labels,
+		}
+		if len(b.cfg.Program) > 0 {
+			program, err := instructions.ToProgram(b.cfg.Program)
+			if err != nil {
+				return err
+			}
+			for i, instr := range program {
+				switch typed := instr.(type) {
+				case instructions.ConfigMutator:
+					if err := typed.MutateConfig(&configSpec); err != nil {
+						return errors.Wrapf(err, "failed to apply instruction %d", i)
+					}
+				case instructions.Copy:
+					staged, err := typed.StageFiles(buildOutputDir)
+					if err != nil {
+						return errors.Wrapf(err, "failed to apply instruction %d", i)
+					}
+					layerFiles = append(layerFiles, staged...)
+				default:
+					return errors.Errorf("instruction %d is not supported by the daemonless builder; Run and RunAll require a container runtime", i)
+				}
+			}
+		}
+
+		manifestDesc, err := ocilayout.BuildPlatformManifest(reg, layout, ocilayout.PlatformImage{
+			OS:           platformOS,
+			Architecture: platformArch,
+			BaseImage:    baseRef,
+			Files:        layerFiles,
+			Config:       configSpec,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to build manifest for %s", osArch)
+		}
+		manifestDescs = append(manifestDescs, manifestDesc)
+	}
+
+	if err := layout.WriteIndex(manifestDescs); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "wrote OCI layout for %s %s to %s\n", productName, version, layoutDir)
+	return nil
+}
+
+func fileModeOrDefault(m int64) os.FileMode {
+	if m == 0 {
+		return 0644
+	}
+	return os.FileMode(m)
+}
@@ -0,0 +1,129 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dockerbuilder provides the built-in distgo.DockerBuilder implementations and the
+// factory used to look them up by their configured type name.
+package dockerbuilder
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/palantir/distgo/distgo"
+	"github.com/palantir/distgo/dockerbuilder/instructions"
+)
+
+// DefaultDockerBuilderTypeName is the name of the default docker builder, which shells out to
+// "docker build" using a Dockerfile on disk. It requires a running Docker daemon.
+const DefaultDockerBuilderTypeName = "default"
+
+// DefaultConfig is the YAML-serializable configuration for the default docker builder. Exactly
+// one of DockerfilePath or Program should be set: DockerfilePath points at a Dockerfile that
+// already exists in the build context, while Program describes the build as a typed
+// []instructions.Instruction, which is compiled to a Dockerfile before the build runs.
+type DefaultConfig struct {
+	DockerfilePath string              `yaml:"dockerfilePath,omitempty" json:"dockerfilePath,omitempty"`
+	Program        []instructions.Step `yaml:"program,omitempty" json:"program,omitempty"`
+	// BaseImage is the image that the generated "FROM" line uses. It is required when Program is set.
+	BaseImage string   `yaml:"baseImage,omitempty" json:"baseImage,omitempty"`
+	Tags      []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+}
+
+type defaultDockerBuilder struct {
+	cfg DefaultConfig
+}
+
+func newDefaultDockerBuilderFromConfig(cfgYAML string) (distgo.DockerBuilder, error) {
+	var cfg DefaultConfig
+	if err := unmarshalDockerBuilderConfig(cfgYAML, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal %s config", DefaultDockerBuilderTypeName)
+	}
+	return &defaultDockerBuilder{cfg: cfg}, nil
+}
+
+// TypeName returns the registered name of this docker builder.
+func (b *defaultDockerBuilder) TypeName() (string, error) {
+	return DefaultDockerBuilderTypeName, nil
+}
+
+// RunDockerBuild invokes "docker build" against the configured Dockerfile (or, if Program is
+// set, against a Dockerfile compiled from it), requiring a running Docker daemon on the host.
+func (b *defaultDockerBuilder) RunDockerBuild(productName, version string, buildOutputDirs map[string]string, contextDir string, w io.Writer) error {
+	dockerfilePath := b.cfg.DockerfilePath
+	if len(b.cfg.Program) > 0 {
+		if dockerfilePath != "" {
+			return errors.New("default docker builder config must set only one of DockerfilePath and Program")
+		}
+		compiled, err := b.compileProgram()
+		if err != nil {
+			return err
+		}
+		generatedPath := filepath.Join(contextDir, ".godel-dockerfile-generated")
+		if err := ioutil.WriteFile(generatedPath, []byte(compiled), 0644); err != nil {
+			return errors.Wrapf(err, "failed to write generated Dockerfile to %q", generatedPath)
+		}
+		defer func() { _ = os.Remove(generatedPath) }()
+		dockerfilePath = generatedPath
+	}
+	if dockerfilePath == "" {
+		dockerfilePath = "Dockerfile"
+	}
+	args := []string{"build", "-f", dockerfilePath}
+	for _, tag := range b.cfg.Tags {
+		args = append(args, "-t", tag)
+	}
+	args = append(args, contextDir)
+
+	cmd := exec.Command("docker", args...)
+	cmd.Dir = contextDir
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Run(); err != nil {
+		return errors.Wrapf(err, "docker build failed for %s", productName)
+	}
+	return nil
+}
+
+// compileProgram compiles b.cfg.Program to a complete Dockerfile body, prefixed with a "FROM"
+// line for b.cfg.BaseImage.
+func (b *defaultDockerBuilder) compileProgram() (string, error) {
+	if b.cfg.BaseImage == "" {
+		return "", errors.New("default docker builder config must set baseImage when Program is set")
+	}
+	program, err := instructions.ToProgram(b.cfg.Program)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to build instruction program")
+	}
+	compiled, err := instructions.Compile(program)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to compile instruction program")
+	}
+	return "FROM " + b.cfg.BaseImage + "\n" + compiled + "\n", nil
+}
+
+// unmarshalDockerBuilderConfig decodes a docker builder's configuration payload, treating the
+// empty string as an empty object. The payload is decoded as JSON, so a YAML payload that is not
+// also valid JSON is rejected.
+func unmarshalDockerBuilderConfig(cfgYAML string, out interface{}) error {
+	if cfgYAML == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(cfgYAML), out)
+}
@@ -0,0 +1,49 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dockerbuilder
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/palantir/distgo/distgo"
+)
+
+// NewDockerBuilderFactory returns a distgo.DockerBuilderFactory with all of the built-in docker
+// builder types registered ("default", which shells out to "docker build", and "daemonless",
+// which builds an OCI layout directly without a Docker daemon), plus any third-party docker
+// builder plugins discovered on $PATH or plugin.DirEnvVar (see the dockerbuilder/plugin.go doc
+// comment).
+func NewDockerBuilderFactory() (*distgo.DockerBuilderFactory, error) {
+	factory, err := distgo.NewDockerBuilderFactory()
+	if err != nil {
+		return nil, err
+	}
+	registrations := []struct {
+		typeName string
+		creator  distgo.DockerBuilderCreator
+	}{
+		{DefaultDockerBuilderTypeName, newDefaultDockerBuilderFromConfig},
+		{DaemonlessDockerBuilderTypeName, newDaemonlessDockerBuilderFromConfig},
+	}
+	for _, r := range registrations {
+		if err := factory.Register(r.typeName, r.creator); err != nil {
+			return nil, errors.Wrapf(err, "failed to register docker builder type %q", r.typeName)
+		}
+	}
+	if err := registerPlugins(factory); err != nil {
+		return nil, err
+	}
+	return factory, nil
+}
@@ -0,0 +1,344 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package instructions models Dockerfile/OCI build steps as concrete Go types, rather than as a
+// templated Dockerfile string. A []Instruction program can be compiled to a Dockerfile (for the
+// default, daemon-backed builder) or applied directly to an image config (for the instructions
+// that the daemonless builder can express without a container runtime).
+package instructions
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/palantir/distgo/dockerbuilder/ocilayout"
+)
+
+func filepathGlob(buildOutputDir, src string) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(buildOutputDir, src))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve glob %q", src)
+	}
+	return matches, nil
+}
+
+// Instruction is a single Dockerfile/OCI build step. Every Instruction can be compiled to a
+// Dockerfile line; instructions that only affect the image's runtime config also implement
+// ConfigMutator so that the daemonless builder can apply them without a container runtime.
+type Instruction interface {
+	// Compile renders this instruction as one or more Dockerfile lines.
+	Compile() (string, error)
+}
+
+// ConfigMutator is implemented by instructions whose entire effect is a change to the image's
+// runtime config (Env, Label, User, WorkDir, Entrypoint, Cmd, Volume, Expose). The daemonless
+// builder applies these directly; instructions that are not ConfigMutators (Run, Copy) require
+// either the Dockerfile-based builder or, for Copy, are staged as layer content directly.
+type ConfigMutator interface {
+	MutateConfig(cfg *ocilayout.ImageConfigSpec) error
+}
+
+// shellQuote quotes s for safe inclusion in a double-quoted JSON-array Dockerfile form, escaping
+// backslashes and double quotes.
+func shellQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+func jsonArray(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// Run is a single shell command run in its own layer.
+type Run struct {
+	Command string
+}
+
+// Compile renders this instruction as a single "RUN <command>" line.
+func (r Run) Compile() (string, error) {
+	if r.Command == "" {
+		return "", errors.New("Run instruction requires a non-empty Command")
+	}
+	return "RUN " + r.Command, nil
+}
+
+// RunAll reduces a slice of shell commands into a single "RUN a && b && c" line (joined with
+// " && \\\n    " for readability) so that they share one layer instead of one per command.
+type RunAll struct {
+	Commands []string
+}
+
+// Compile renders this instruction as a single multi-command RUN line.
+func (r RunAll) Compile() (string, error) {
+	if len(r.Commands) == 0 {
+		return "", errors.New("RunAll instruction requires at least one command")
+	}
+	return "RUN " + strings.Join(r.Commands, " && \\\n    "), nil
+}
+
+// Copy copies files from the build context into the image.
+type Copy struct {
+	Src  string
+	Dst  string
+	From string // optional multi-stage build source
+}
+
+// Compile renders this instruction as a "COPY [--from=stage] <src> <dst>" line.
+func (c Copy) Compile() (string, error) {
+	if c.Src == "" || c.Dst == "" {
+		return "", errors.New("Copy instruction requires both Src and Dst")
+	}
+	if c.From != "" {
+		return fmt.Sprintf("COPY --from=%s %s %s", c.From, c.Src, c.Dst), nil
+	}
+	return fmt.Sprintf("COPY %s %s", c.Src, c.Dst), nil
+}
+
+// StageFiles resolves this Copy against buildOutputDir for the daemonless builder, which applies
+// Copy instructions directly to the layer being built rather than through a container runtime.
+func (c Copy) StageFiles(buildOutputDir string) ([]ocilayout.StagedFile, error) {
+	if c.From != "" {
+		return nil, errors.Errorf("Copy --from=%s is not supported by the daemonless builder; multi-stage copies require the Dockerfile-based builder", c.From)
+	}
+	matches, err := filepathGlob(buildOutputDir, c.Src)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, errors.Errorf("Copy glob %q did not match any files in %s", c.Src, buildOutputDir)
+	}
+	if len(matches) > 1 && !strings.HasSuffix(c.Dst, "/") {
+		return nil, errors.Errorf("Copy glob %q matches multiple files, so Dst %q must end with \"/\" to be used as a destination directory", c.Src, c.Dst)
+	}
+	staged := make([]ocilayout.StagedFile, 0, len(matches))
+	for _, m := range matches {
+		dst := c.Dst
+		if len(matches) > 1 {
+			dst = filepath.Join(c.Dst, filepath.Base(m))
+		}
+		staged = append(staged, ocilayout.StagedFile{
+			Path:    strings.TrimPrefix(dst, "/"),
+			SrcPath: m,
+			Mode:    0644,
+		})
+	}
+	return staged, nil
+}
+
+// Env sets one or more environment variables.
+type Env struct {
+	Vars map[string]string
+}
+
+// Compile renders this instruction as a single "ENV k=v ..." line, with variables sorted by name
+// for reproducible output.
+func (e Env) Compile() (string, error) {
+	if len(e.Vars) == 0 {
+		return "", errors.New("Env instruction requires at least one variable")
+	}
+	var parts []string
+	for _, k := range sortedVarNames(e.Vars) {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, shellQuote(e.Vars[k])))
+	}
+	return "ENV " + strings.Join(parts, " "), nil
+}
+
+// MutateConfig appends this instruction's variables to the image config's Env list.
+func (e Env) MutateConfig(cfg *ocilayout.ImageConfigSpec) error {
+	for _, k := range sortedVarNames(e.Vars) {
+		cfg.Env = append(cfg.Env, fmt.Sprintf("%s=%s", k, e.Vars[k]))
+	}
+	return nil
+}
+
+func sortedVarNames(vars map[string]string) []string {
+	names := make([]string, 0, len(vars))
+	for k := range vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Label sets one or more OCI image labels.
+type Label struct {
+	Labels map[string]string
+}
+
+// Compile renders this instruction as a single "LABEL k=v ..." line.
+func (l Label) Compile() (string, error) {
+	if len(l.Labels) == 0 {
+		return "", errors.New("Label instruction requires at least one label")
+	}
+	var parts []string
+	for _, k := range sortedVarNames(l.Labels) {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, shellQuote(l.Labels[k])))
+	}
+	return "LABEL " + strings.Join(parts, " "), nil
+}
+
+// MutateConfig merges this instruction's labels into the image config's Labels map.
+func (l Label) MutateConfig(cfg *ocilayout.ImageConfigSpec) error {
+	if cfg.Labels == nil {
+		cfg.Labels = map[string]string{}
+	}
+	for k, v := range l.Labels {
+		cfg.Labels[k] = v
+	}
+	return nil
+}
+
+// User sets the user (and optional group) that subsequent instructions and the container run as.
+type User struct {
+	User string
+}
+
+// Compile renders this instruction as a "USER <user>" line.
+func (u User) Compile() (string, error) {
+	if u.User == "" {
+		return "", errors.New("User instruction requires a non-empty User")
+	}
+	return "USER " + u.User, nil
+}
+
+// MutateConfig sets the image config's User field.
+func (u User) MutateConfig(cfg *ocilayout.ImageConfigSpec) error {
+	cfg.User = u.User
+	return nil
+}
+
+// WorkDir sets the working directory for subsequent instructions and the container's default
+// process.
+type WorkDir struct {
+	Dir string
+}
+
+// Compile renders this instruction as a "WORKDIR <dir>" line.
+func (w WorkDir) Compile() (string, error) {
+	if w.Dir == "" {
+		return "", errors.New("WorkDir instruction requires a non-empty Dir")
+	}
+	return "WORKDIR " + w.Dir, nil
+}
+
+// MutateConfig sets the image config's WorkingDir field.
+func (w WorkDir) MutateConfig(cfg *ocilayout.ImageConfigSpec) error {
+	cfg.WorkingDir = w.Dir
+	return nil
+}
+
+// Entrypoint sets the image's entrypoint (exec form).
+type Entrypoint struct {
+	Args []string
+}
+
+// Compile renders this instruction as an "ENTRYPOINT [...]" line.
+func (e Entrypoint) Compile() (string, error) {
+	if len(e.Args) == 0 {
+		return "", errors.New("Entrypoint instruction requires at least one argument")
+	}
+	return "ENTRYPOINT " + jsonArray(e.Args), nil
+}
+
+// MutateConfig sets the image config's Entrypoint field.
+func (e Entrypoint) MutateConfig(cfg *ocilayout.ImageConfigSpec) error {
+	cfg.Entrypoint = e.Args
+	return nil
+}
+
+// Cmd sets the image's default command (exec form).
+type Cmd struct {
+	Args []string
+}
+
+// Compile renders this instruction as a "CMD [...]" line.
+func (c Cmd) Compile() (string, error) {
+	if len(c.Args) == 0 {
+		return "", errors.New("Cmd instruction requires at least one argument")
+	}
+	return "CMD " + jsonArray(c.Args), nil
+}
+
+// MutateConfig sets the image config's Cmd field.
+func (c Cmd) MutateConfig(cfg *ocilayout.ImageConfigSpec) error {
+	cfg.Cmd = c.Args
+	return nil
+}
+
+// Volume declares a mount point that should be treated as a volume.
+type Volume struct {
+	Path string
+}
+
+// Compile renders this instruction as a "VOLUME [...]" line.
+func (v Volume) Compile() (string, error) {
+	if v.Path == "" {
+		return "", errors.New("Volume instruction requires a non-empty Path")
+	}
+	return "VOLUME " + jsonArray([]string{v.Path}), nil
+}
+
+// MutateConfig adds this instruction's path to the image config's Volumes set.
+func (v Volume) MutateConfig(cfg *ocilayout.ImageConfigSpec) error {
+	if cfg.Volumes == nil {
+		cfg.Volumes = map[string]struct{}{}
+	}
+	cfg.Volumes[v.Path] = struct{}{}
+	return nil
+}
+
+// Expose documents a port that the container listens on.
+type Expose struct {
+	Port int
+}
+
+// Compile renders this instruction as an "EXPOSE <port>" line.
+func (e Expose) Compile() (string, error) {
+	if e.Port <= 0 {
+		return "", errors.Errorf("Expose instruction requires a positive Port, got %d", e.Port)
+	}
+	return fmt.Sprintf("EXPOSE %d", e.Port), nil
+}
+
+// MutateConfig adds this instruction's port to the image config's ExposedPorts set.
+func (e Expose) MutateConfig(cfg *ocilayout.ImageConfigSpec) error {
+	if cfg.ExposedPorts == nil {
+		cfg.ExposedPorts = map[string]struct{}{}
+	}
+	cfg.ExposedPorts[fmt.Sprintf("%d/tcp", e.Port)] = struct{}{}
+	return nil
+}
+
+// Compile renders program as a complete Dockerfile body (one line, or block, per instruction, in
+// order), suitable for the default Dockerfile-based builder.
+func Compile(program []Instruction) (string, error) {
+	var lines []string
+	for i, instr := range program {
+		line, err := instr.Compile()
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to compile instruction %d", i)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
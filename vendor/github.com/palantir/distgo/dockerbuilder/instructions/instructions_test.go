@@ -0,0 +1,84 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instructions_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palantir/distgo/dockerbuilder/instructions"
+)
+
+func TestRunAllJoinsCommands(t *testing.T) {
+	line, err := instructions.RunAll{Commands: []string{"a", "b", "c"}}.Compile()
+	require.NoError(t, err)
+	assert.Equal(t, "RUN a && \\\n    b && \\\n    c", line)
+}
+
+func TestCompileProgram(t *testing.T) {
+	program := []instructions.Instruction{
+		instructions.Run{Command: "echo hi"},
+		instructions.Env{Vars: map[string]string{"B": "2", "A": "1"}},
+		instructions.Cmd{Args: []string{"/bin/sh"}},
+	}
+	out, err := instructions.Compile(program)
+	require.NoError(t, err)
+	assert.Equal(t, "RUN echo hi\nENV A=\"1\" B=\"2\"\nCMD [\"/bin/sh\"]", out)
+}
+
+func TestStepToInstructionRoundTrip(t *testing.T) {
+	step := instructions.Step{RunAll: []string{"a", "b"}}
+	instr, err := step.ToInstruction()
+	require.NoError(t, err)
+	assert.Equal(t, instructions.RunAll{Commands: []string{"a", "b"}}, instr)
+
+	_, err = instructions.Step{}.ToInstruction()
+	assert.Error(t, err, "a step with no fields set should fail to convert")
+}
+
+func TestAptInstallCleansUpInSameLayer(t *testing.T) {
+	run := instructions.AptInstall("curl", "jq")
+	line, err := run.Compile()
+	require.NoError(t, err)
+	assert.Contains(t, line, "apt-get install -y --no-install-recommends curl jq")
+	assert.Contains(t, line, "rm -rf /var/lib/apt/lists/*")
+}
+
+func TestCopyStageFilesJoinsDstForMultiMatchGlob(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	require.NoError(t, ioutil.WriteFile(path.Join(tmp, "foo"), []byte("foo"), 0755))
+	require.NoError(t, ioutil.WriteFile(path.Join(tmp, "bar"), []byte("bar"), 0755))
+
+	staged, err := instructions.Copy{Src: "*", Dst: "/usr/bin/"}.StageFiles(tmp)
+	require.NoError(t, err)
+	require.Len(t, staged, 2)
+
+	var paths []string
+	for _, s := range staged {
+		paths = append(paths, s.Path)
+	}
+	assert.ElementsMatch(t, []string{"usr/bin/bar", "usr/bin/foo"}, paths)
+
+	_, err = instructions.Copy{Src: "*", Dst: "/usr/bin/app"}.StageFiles(tmp)
+	assert.Error(t, err, "a multi-match glob with a non-directory Dst should be rejected")
+}
@@ -0,0 +1,42 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instructions
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AptInstall returns a RunAll instruction that refreshes the package index, installs packages via
+// apt-get and removes the downloaded package lists (/var/lib/apt/lists) in the same layer, so that
+// the lists do not remain in the image.
+func AptInstall(packages ...string) RunAll {
+	return RunAll{Commands: []string{
+		"apt-get update",
+		"apt-get install -y --no-install-recommends " + strings.Join(packages, " "),
+		"rm -rf /var/lib/apt/lists/*",
+	}}
+}
+
+// NpmInstall returns a RunAll instruction that installs packages globally via npm with the
+// audit/fund banners disabled, then clears the npm cache in the same layer so that the cache does
+// not remain in the image.
+func NpmInstall(packages ...string) RunAll {
+	args := append([]string{"--no-audit", "--no-fund", "--global"}, packages...)
+	return RunAll{Commands: []string{
+		fmt.Sprintf("npm install %s", strings.Join(args, " ")),
+		"npm cache clean --force",
+	}}
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package instructions
+
+import "github.com/pkg/errors"
+
+// Step is the YAML-serializable form of a single Instruction: exactly one of its fields should be
+// set, naming which Instruction it represents. This lets a DockerBuilderConfig describe a build
+// program ([]Step) in configuration instead of (or alongside) a raw Dockerfile path.
+type Step struct {
+	Run        string            `yaml:"run,omitempty" json:"run,omitempty"`
+	RunAll     []string          `yaml:"runAll,omitempty" json:"runAll,omitempty"`
+	Copy       *CopyStep         `yaml:"copy,omitempty" json:"copy,omitempty"`
+	Env        map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+	Label      map[string]string `yaml:"label,omitempty" json:"label,omitempty"`
+	User       string            `yaml:"user,omitempty" json:"user,omitempty"`
+	WorkDir    string            `yaml:"workDir,omitempty" json:"workDir,omitempty"`
+	Entrypoint []string          `yaml:"entrypoint,omitempty" json:"entrypoint,omitempty"`
+	Cmd        []string          `yaml:"cmd,omitempty" json:"cmd,omitempty"`
+	Volume     string            `yaml:"volume,omitempty" json:"volume,omitempty"`
+	Expose     int               `yaml:"expose,omitempty" json:"expose,omitempty"`
+}
+
+// CopyStep is the YAML-serializable form of a Copy instruction.
+type CopyStep struct {
+	Src  string `yaml:"src" json:"src"`
+	Dst  string `yaml:"dst" json:"dst"`
+	From string `yaml:"from,omitempty" json:"from,omitempty"`
+}
+
+// ToInstruction converts s to the concrete Instruction it describes. Exactly one of s's fields
+// must be set.
+func (s Step) ToInstruction() (Instruction, error) {
+	switch {
+	case s.Run != "":
+		return Run{Command: s.Run}, nil
+	case len(s.RunAll) > 0:
+		return RunAll{Commands: s.RunAll}, nil
+	case s.Copy != nil:
+		return Copy{Src: s.Copy.Src, Dst: s.Copy.Dst, From: s.Copy.From}, nil
+	case len(s.Env) > 0:
+		return Env{Vars: s.Env}, nil
+	case len(s.Label) > 0:
+		return Label{Labels: s.Label}, nil
+	case s.User != "":
+		return User{User: s.User}, nil
+	case s.WorkDir != "":
+		return WorkDir{Dir: s.WorkDir}, nil
+	case len(s.Entrypoint) > 0:
+		return Entrypoint{Args: s.Entrypoint}, nil
+	case len(s.Cmd) > 0:
+		return Cmd{Args: s.Cmd}, nil
+	case s.Volume != "":
+		return Volume{Path: s.Volume}, nil
+	case s.Expose != 0:
+		return Expose{Port: s.Expose}, nil
+	default:
+		return nil, errors.New("step does not set any instruction field")
+	}
+}
+
+// ToProgram converts steps to the []Instruction program they describe, in order.
+func ToProgram(steps []Step) ([]Instruction, error) {
+	program := make([]Instruction, 0, len(steps))
+	for i, s := range steps {
+		instr, err := s.ToInstruction()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid step %d", i)
+		}
+		program = append(program, instr)
+	}
+	return program, nil
+}
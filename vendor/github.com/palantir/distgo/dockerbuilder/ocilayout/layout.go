@@ -0,0 +1,300 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ocilayout builds and reads OCI image layouts (https://github.com/opencontainers/image-spec)
+// directly on disk, without requiring a running Docker daemon. It is used by both the "oci"
+// dister (which produces a layout as a dist artifact) and the daemonless docker builder (which
+// builds a layout and can push/copy it to other transports).
+package ocilayout
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// MediaTypeImageLayerGzip is the media type of a gzip-compressed image rootfs layer.
+	MediaTypeImageLayerGzip = "application/vnd.oci.image.layer.v1.tar+gzip"
+	// MediaTypeImageConfig is the media type of an image config blob.
+	MediaTypeImageConfig = "application/vnd.oci.image.config.v1+json"
+	// MediaTypeImageManifest is the media type of an image manifest.
+	MediaTypeImageManifest = "application/vnd.oci.image.manifest.v1+json"
+	// MediaTypeImageIndex is the media type of an image index.
+	MediaTypeImageIndex = "application/vnd.oci.image.index.v1+json"
+
+	layoutVersion = "1.0.0"
+)
+
+// Descriptor is an OCI content descriptor: a reference to a blob by digest, size and media type.
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Platform    *Platform         `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Platform identifies the os/arch that an image manifest targets.
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+// Index is the content of an OCI "index.json" file: the entry point into the layout, listing one
+// manifest descriptor per platform.
+type Index struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Manifests     []Descriptor `json:"manifests"`
+}
+
+// Manifest is the content of an OCI image manifest blob.
+type Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        Descriptor   `json:"config"`
+	Layers        []Descriptor `json:"layers"`
+}
+
+// ImageConfig is the content of an OCI image config blob (a reduced view carrying only the
+// fields that distgo's builders populate).
+type ImageConfig struct {
+	Architecture string            `json:"architecture"`
+	OS           string            `json:"os"`
+	Config       ImageConfigSpec   `json:"config"`
+	RootFS       ImageConfigRootFS `json:"rootfs"`
+	History      []ImageHistory    `json:"history,omitempty"`
+}
+
+// ImageConfigSpec carries the runtime configuration (entrypoint, env, etc.) of an image.
+type ImageConfigSpec struct {
+	Env          []string            `json:"Env,omitempty"`
+	Entrypoint   []string            `json:"Entrypoint,omitempty"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	WorkingDir   string              `json:"WorkingDir,omitempty"`
+	User         string              `json:"User,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+	Volumes      map[string]struct{} `json:"Volumes,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+}
+
+// ImageConfigRootFS lists the diff IDs (uncompressed layer digests) that make up an image's
+// filesystem, in application order.
+type ImageConfigRootFS struct {
+	Type    string   `json:"type"`
+	DiffIDs []string `json:"diff_ids"`
+}
+
+// ImageHistory is a single entry in an image's build history.
+type ImageHistory struct {
+	CreatedBy string `json:"created_by"`
+}
+
+// Layout is an OCI image layout directory on disk. Its methods write blobs and index.json into
+// that directory as they are called.
+type Layout struct {
+	dir string
+}
+
+// New creates (or reuses) an OCI image layout rooted at dir, writing the "oci-layout" marker
+// file and an empty "blobs/sha256" directory.
+func New(dir string) (*Layout, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create layout directory %q", dir)
+	}
+	marker := fmt.Sprintf(`{"imageLayoutVersion":%q}`, layoutVersion)
+	if err := ioutil.WriteFile(filepath.Join(dir, "oci-layout"), []byte(marker), 0644); err != nil {
+		return nil, errors.Wrapf(err, "failed to write oci-layout marker in %q", dir)
+	}
+	return &Layout{dir: dir}, nil
+}
+
+// StagedFile is a single file (or symlink) destined for a layer's tar payload.
+type StagedFile struct {
+	Path     string
+	SrcPath  string
+	LinkPath string
+	Mode     os.FileMode
+}
+
+// WriteLayer builds a reproducible gzip-compressed tar layer from files (sorted entries, zeroed
+// mtimes, zeroed uid/gid) and writes it into the layout's blob store, returning the descriptor of
+// the compressed blob and the diffID (the digest of the uncompressed tar) that belongs in the
+// image config's rootfs.
+func (l *Layout) WriteLayer(files []StagedFile) (Descriptor, string, error) {
+	sorted := append([]StagedFile(nil), files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+	uncompressed, err := buildReproducibleTar(sorted)
+	if err != nil {
+		return Descriptor{}, "", err
+	}
+	diffIDSum := sha256.Sum256(uncompressed)
+	diffID := "sha256:" + hex.EncodeToString(diffIDSum[:])
+
+	compressed, err := gzipBytes(uncompressed)
+	if err != nil {
+		return Descriptor{}, "", err
+	}
+	desc, err := l.writeBlob(MediaTypeImageLayerGzip, compressed)
+	if err != nil {
+		return Descriptor{}, "", err
+	}
+	return desc, diffID, nil
+}
+
+// WriteConfig writes cfg as the image config blob and returns its descriptor.
+func (l *Layout) WriteConfig(cfg ImageConfig) (Descriptor, error) {
+	bytes, err := json.Marshal(cfg)
+	if err != nil {
+		return Descriptor{}, errors.Wrap(err, "failed to marshal image config")
+	}
+	return l.writeBlob(MediaTypeImageConfig, bytes)
+}
+
+// WriteManifest writes manifest as a manifest blob and returns its descriptor.
+func (l *Layout) WriteManifest(manifest Manifest) (Descriptor, error) {
+	bytes, err := json.Marshal(manifest)
+	if err != nil {
+		return Descriptor{}, errors.Wrap(err, "failed to marshal image manifest")
+	}
+	desc, err := l.writeBlob(MediaTypeImageManifest, bytes)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	return desc, nil
+}
+
+// WriteIndex writes index.json at the root of the layout, referencing the given manifest
+// descriptors (one per platform).
+func (l *Layout) WriteIndex(manifests []Descriptor) error {
+	index := Index{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeImageIndex,
+		Manifests:     manifests,
+	}
+	bytes, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal index.json")
+	}
+	if err := ioutil.WriteFile(filepath.Join(l.dir, "index.json"), bytes, 0644); err != nil {
+		return errors.Wrapf(err, "failed to write index.json in %q", l.dir)
+	}
+	return nil
+}
+
+// BlobPath returns the on-disk path of a blob given its "sha256:<hex>" digest.
+func (l *Layout) BlobPath(digest string) string {
+	return filepath.Join(l.dir, "blobs", "sha256", DigestHex(digest))
+}
+
+// WriteRawBlob writes an already-encoded blob (e.g. one fetched from a registry) to the layout's
+// blob store and returns its descriptor.
+func (l *Layout) WriteRawBlob(mediaType string, content []byte) (Descriptor, error) {
+	return l.writeBlob(mediaType, content)
+}
+
+func (l *Layout) writeBlob(mediaType string, content []byte) (Descriptor, error) {
+	sum := sha256.Sum256(content)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	path := l.BlobPath(digest)
+	if _, err := os.Stat(path); err == nil {
+		// content-addressed: identical blobs are already present.
+		return Descriptor{MediaType: mediaType, Digest: digest, Size: int64(len(content))}, nil
+	}
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		return Descriptor{}, errors.Wrapf(err, "failed to write blob %q", digest)
+	}
+	return Descriptor{MediaType: mediaType, Digest: digest, Size: int64(len(content))}, nil
+}
+
+// DigestHex returns the hex-encoded half of a "sha256:<hex>" digest string.
+func DigestHex(digest string) string {
+	const prefix = "sha256:"
+	if len(digest) > len(prefix) && digest[:len(prefix)] == prefix {
+		return digest[len(prefix):]
+	}
+	return digest
+}
+
+// buildReproducibleTar writes files as a tar stream with sorted entries, zeroed mtimes and
+// zeroed uid/gid so that identical inputs always produce byte-identical layers.
+func buildReproducibleTar(files []StagedFile) ([]byte, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		if f.LinkPath != "" {
+			hdr := &tar.Header{
+				Name:     f.Path,
+				Typeflag: tar.TypeSymlink,
+				Linkname: f.LinkPath,
+				Mode:     int64(f.Mode.Perm()),
+				ModTime:  time.Unix(0, 0),
+			}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return nil, errors.Wrap(err, "failed to write layer tar header")
+			}
+			continue
+		}
+		contents, err := ioutil.ReadFile(f.SrcPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %q", f.SrcPath)
+		}
+		hdr := &tar.Header{
+			Name:    f.Path,
+			Size:    int64(len(contents)),
+			Mode:    int64(f.Mode.Perm()),
+			ModTime: time.Unix(0, 0),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, errors.Wrap(err, "failed to write layer tar header")
+		}
+		if _, err := tw.Write(contents); err != nil {
+			return nil, errors.Wrap(err, "failed to write layer tar content")
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close layer tar")
+	}
+	return buf.Bytes(), nil
+}
+
+func gzipBytes(content []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	// a fixed mtime keeps the gzip header itself reproducible.
+	gw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	gw.ModTime = time.Unix(0, 0)
+	if _, err := gw.Write(content); err != nil {
+		return nil, errors.Wrap(err, "failed to gzip layer")
+	}
+	if err := gw.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close layer gzip stream")
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ocilayout_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palantir/distgo/dockerbuilder/ocilayout"
+)
+
+func TestWriteLayerIsReproducible(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	require.NoError(t, ioutil.WriteFile(path.Join(tmp, "foo"), []byte("hello"), 0755))
+
+	files := []ocilayout.StagedFile{
+		{Path: "usr/bin/foo", SrcPath: path.Join(tmp, "foo"), Mode: 0755},
+	}
+
+	layoutDirA := path.Join(tmp, "a")
+	layoutA, err := ocilayout.New(layoutDirA)
+	require.NoError(t, err)
+	descA, diffIDA, err := layoutA.WriteLayer(files)
+	require.NoError(t, err)
+
+	layoutDirB := path.Join(tmp, "b")
+	layoutB, err := ocilayout.New(layoutDirB)
+	require.NoError(t, err)
+	descB, diffIDB, err := layoutB.WriteLayer(files)
+	require.NoError(t, err)
+
+	assert.Equal(t, descA.Digest, descB.Digest, "identical inputs should produce identical layer digests")
+	assert.Equal(t, diffIDA, diffIDB, "identical inputs should produce identical diffIDs")
+
+	info, err := os.Stat(layoutA.BlobPath(descA.Digest))
+	require.NoError(t, err)
+	assert.False(t, info.IsDir())
+}
+
+func TestWriteIndexAndOCILayoutMarker(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	layout, err := ocilayout.New(tmp)
+	require.NoError(t, err)
+
+	marker, err := ioutil.ReadFile(path.Join(tmp, "oci-layout"))
+	require.NoError(t, err)
+	assert.Contains(t, string(marker), "imageLayoutVersion")
+
+	configDesc, err := layout.WriteConfig(ocilayout.ImageConfig{OS: "linux", Architecture: "amd64"})
+	require.NoError(t, err)
+
+	require.NoError(t, layout.WriteIndex([]ocilayout.Descriptor{configDesc}))
+	index, err := ioutil.ReadFile(path.Join(tmp, "index.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(index), configDesc.Digest)
+}
@@ -0,0 +1,143 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ocilayout
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PlatformImage describes the inputs needed to build one platform's manifest within an OCI
+// layout: the product's own layer plus the runtime configuration it should carry. It is shared by
+// the "oci" dister and the daemonless docker builder, which otherwise duplicate this assembly
+// logic per os/arch.
+type PlatformImage struct {
+	OS           string
+	Architecture string
+	// BaseImage is an optional registry reference whose layers/config for OS/Architecture are
+	// fetched (via reg) and prepended to Files. The zero Ref means no base image.
+	BaseImage Ref
+	Files     []StagedFile
+	Config    ImageConfigSpec
+	// CreatedBy, if non-empty, becomes the image config's sole history entry.
+	CreatedBy string
+}
+
+// BuildPlatformManifest assembles img (fetching and restaging img.BaseImage's layers/config into
+// layout first, if set) into layout, writing the resulting image config and manifest blobs, and
+// returns the platform-tagged manifest descriptor that should be accumulated into the layout's
+// final index. reg may be nil if img.BaseImage is the zero Ref.
+func BuildPlatformManifest(reg *RegistryClient, layout *Layout, img PlatformImage) (Descriptor, error) {
+	var parentLayers []Descriptor
+	var diffIDs []string
+	cfg := img.Config
+	if img.BaseImage != (Ref{}) {
+		baseManifest, baseConfig, err := fetchBaseImage(reg, img.BaseImage, layout, img.OS, img.Architecture)
+		if err != nil {
+			return Descriptor{}, errors.Wrapf(err, "failed to fetch base image %q for %s/%s", img.BaseImage, img.OS, img.Architecture)
+		}
+		parentLayers = baseManifest.Layers
+		diffIDs = baseConfig.RootFS.DiffIDs
+		cfg.Env = append(append([]string(nil), baseConfig.Config.Env...), cfg.Env...)
+	}
+
+	layerDesc, diffID, err := layout.WriteLayer(img.Files)
+	if err != nil {
+		return Descriptor{}, err
+	}
+
+	var history []ImageHistory
+	if img.CreatedBy != "" {
+		history = []ImageHistory{{CreatedBy: img.CreatedBy}}
+	}
+	configDesc, err := layout.WriteConfig(ImageConfig{
+		Architecture: img.Architecture,
+		OS:           img.OS,
+		Config:       cfg,
+		RootFS: ImageConfigRootFS{
+			Type:    "layers",
+			DiffIDs: append(append([]string(nil), diffIDs...), diffID),
+		},
+		History: history,
+	})
+	if err != nil {
+		return Descriptor{}, err
+	}
+
+	manifestDesc, err := layout.WriteManifest(Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeImageManifest,
+		Config:        configDesc,
+		Layers:        append(parentLayers, layerDesc),
+	})
+	if err != nil {
+		return Descriptor{}, err
+	}
+	manifestDesc.Platform = &Platform{OS: img.OS, Architecture: img.Architecture}
+	return manifestDesc, nil
+}
+
+// fetchBaseImage fetches baseRef's manifest and config for platformOS/platformArch and copies its
+// layer and config blobs into layout so that they can be reused as parent layers.
+func fetchBaseImage(reg *RegistryClient, baseRef Ref, layout *Layout, platformOS, platformArch string) (*Manifest, *ImageConfig, error) {
+	manifest, _, err := reg.FetchManifestForPlatform(baseRef, platformOS, platformArch)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, l := range manifest.Layers {
+		blob, err := reg.FetchBlob(baseRef, l.Digest)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to fetch base layer %q", l.Digest)
+		}
+		if _, err := layout.WriteRawBlob(l.MediaType, blob); err != nil {
+			return nil, nil, err
+		}
+	}
+	configBlob, err := reg.FetchBlob(baseRef, manifest.Config.Digest)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to fetch base image config")
+	}
+	if _, err := layout.WriteRawBlob(manifest.Config.MediaType, configBlob); err != nil {
+		return nil, nil, err
+	}
+	config, err := DecodeImageConfig(configBlob)
+	if err != nil {
+		return nil, nil, err
+	}
+	return manifest, config, nil
+}
+
+// SortedOSArches returns buildOutputDirs' os/arch keys (e.g. "linux-amd64") in sorted order, so
+// that disters and docker builders can build multi-platform manifests in a deterministic order.
+func SortedOSArches(buildOutputDirs map[string]string) []string {
+	keys := make([]string, 0, len(buildOutputDirs))
+	for k := range buildOutputDirs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SplitOSArch splits an "os-arch" build output key (e.g. "linux-amd64") into its os and arch
+// parts.
+func SplitOSArch(osArch string) (string, string, error) {
+	parts := strings.SplitN(osArch, "-", 2)
+	if len(parts) != 2 {
+		return "", "", errors.Errorf("invalid os/arch key %q: expected \"<os>-<arch>\"", osArch)
+	}
+	return parts[0], parts[1], nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ocilayout_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palantir/distgo/dockerbuilder/ocilayout"
+)
+
+func TestBuildPlatformManifestWritesConfigAndPlatform(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	require.NoError(t, ioutil.WriteFile(path.Join(tmp, "foo"), []byte("hello"), 0755))
+
+	layout, err := ocilayout.New(path.Join(tmp, "layout"))
+	require.NoError(t, err)
+
+	manifestDesc, err := ocilayout.BuildPlatformManifest(nil, layout, ocilayout.PlatformImage{
+		OS:           "linux",
+		Architecture: "amd64",
+		Files: []ocilayout.StagedFile{
+			{Path: "usr/bin/foo", SrcPath: path.Join(tmp, "foo"), Mode: 0755},
+		},
+		Config:    ocilayout.ImageConfigSpec{User: "nobody"},
+		CreatedBy: "test",
+	})
+	require.NoError(t, err)
+
+	require.NotNil(t, manifestDesc.Platform)
+	assert.Equal(t, "linux", manifestDesc.Platform.OS)
+	assert.Equal(t, "amd64", manifestDesc.Platform.Architecture)
+
+	configBlob, err := ioutil.ReadFile(layout.BlobPath(manifestDescConfigDigest(t, layout, manifestDesc)))
+	require.NoError(t, err)
+	config, err := ocilayout.DecodeImageConfig(configBlob)
+	require.NoError(t, err)
+	assert.Equal(t, "nobody", config.Config.User)
+	require.Len(t, config.History, 1)
+	assert.Equal(t, "test", config.History[0].CreatedBy)
+}
+
+// manifestDescConfigDigest reads the manifest blob that manifestDesc refers to and returns its
+// config descriptor's digest, so the test can load the config blob it wrote.
+func manifestDescConfigDigest(t *testing.T, layout *ocilayout.Layout, manifestDesc ocilayout.Descriptor) string {
+	t.Helper()
+	manifestBytes, err := ioutil.ReadFile(layout.BlobPath(manifestDesc.Digest))
+	require.NoError(t, err)
+	var manifest ocilayout.Manifest
+	require.NoError(t, json.Unmarshal(manifestBytes, &manifest))
+	return manifest.Config.Digest
+}
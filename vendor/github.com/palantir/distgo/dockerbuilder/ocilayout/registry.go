@@ -0,0 +1,330 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ocilayout
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Ref identifies an image in a registry's v2 API: "<host>/<name>:<tag>". ParseRef does not
+// recognize "...@<digest>" references.
+type Ref struct {
+	Host string
+	Name string
+	Tag  string
+}
+
+// ParseRef parses a "host/name:tag" image reference.
+func ParseRef(ref string) (Ref, error) {
+	name := ref
+	tag := "latest"
+	if idx := strings.LastIndex(ref, ":"); idx > strings.LastIndex(ref, "/") {
+		name = ref[:idx]
+		tag = ref[idx+1:]
+	}
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) != 2 {
+		return Ref{}, errors.Errorf("invalid image reference %q: expected <host>/<name>", ref)
+	}
+	return Ref{Host: parts[0], Name: parts[1], Tag: tag}, nil
+}
+
+func (r Ref) String() string {
+	return fmt.Sprintf("%s/%s:%s", r.Host, r.Name, r.Tag)
+}
+
+// RegistryClient is a minimal Docker/OCI Distribution (v2) registry client: just enough to pull
+// base image layers for the daemonless builder and push the layouts that distgo produces.
+type RegistryClient struct {
+	HTTPClient *http.Client
+	// Insecure selects plain http instead of https, for talking to local test registries.
+	Insecure bool
+
+	token string
+}
+
+// NewRegistryClient returns a RegistryClient using http.DefaultClient.
+func NewRegistryClient() *RegistryClient {
+	return &RegistryClient{HTTPClient: http.DefaultClient}
+}
+
+func (c *RegistryClient) baseURL(host string) string {
+	scheme := "https"
+	if c.Insecure {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s", scheme, host)
+}
+
+// authenticate performs the bearer-token auth negotiation described by the Distribution spec: an
+// unauthenticated request returns 401 with a "Www-Authenticate: Bearer realm=...,service=...,
+// scope=..." header, which is exchanged for a token at the given realm.
+func (c *RegistryClient) authenticate(resp *http.Response) error {
+	challenge := resp.Header.Get("Www-Authenticate")
+	if challenge == "" {
+		return errors.New("registry returned 401 without a Www-Authenticate challenge")
+	}
+	params := parseBearerChallenge(challenge)
+	realm, ok := params["realm"]
+	if !ok {
+		return errors.Errorf("unsupported auth challenge %q", challenge)
+	}
+	values := url.Values{}
+	if service, ok := params["service"]; ok {
+		values.Set("service", service)
+	}
+	if scope, ok := params["scope"]; ok {
+		values.Set("scope", scope)
+	}
+	tokenURL := realm + "?" + values.Encode()
+	tokenResp, err := c.HTTPClient.Get(tokenURL)
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch auth token from %q", realm)
+	}
+	defer func() { _ = tokenResp.Body.Close() }()
+	if tokenResp.StatusCode != http.StatusOK {
+		return errors.Errorf("auth token request to %q returned status %d", realm, tokenResp.StatusCode)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(tokenResp.Body).Decode(&body); err != nil {
+		return errors.Wrap(err, "failed to decode auth token response")
+	}
+	c.token = firstNonEmptyStr(body.Token, body.AccessToken)
+	if c.token == "" {
+		return errors.Errorf("auth token response from %q did not include a token", realm)
+	}
+	return nil
+}
+
+func firstNonEmptyStr(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+var bearerParamRegexp = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+func parseBearerChallenge(challenge string) map[string]string {
+	params := map[string]string{}
+	for _, match := range bearerParamRegexp.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+	return params
+}
+
+// doAuthenticated issues req, transparently performing bearer-token auth negotiation and retrying
+// once if the registry challenges the request.
+func (c *RegistryClient) doAuthenticated(req *http.Request) (*http.Response, error) {
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusUnauthorized {
+		return resp, nil
+	}
+	_ = resp.Body.Close()
+	if err := c.authenticate(resp); err != nil {
+		return nil, err
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = body
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	return c.HTTPClient.Do(req)
+}
+
+// FetchManifest retrieves ref's manifest, returning its raw bytes and declared media type.
+func (c *RegistryClient) FetchManifest(ref Ref) ([]byte, string, error) {
+	reqURL := fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL(ref.Host), ref.Name, ref.Tag)
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", strings.Join([]string{MediaTypeImageManifest, MediaTypeImageIndex}, ", "))
+	resp, err := c.doAuthenticated(req)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to fetch manifest for %s", ref)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", errors.Errorf("fetching manifest for %s returned status %d", ref, resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", errors.Wrapf(err, "failed to read manifest body for %s", ref)
+	}
+	return body, resp.Header.Get("Content-Type"), nil
+}
+
+// FetchBlob retrieves the blob identified by digest (e.g. a layer or config blob) for ref's repo.
+func (c *RegistryClient) FetchBlob(ref Ref, digest string) ([]byte, error) {
+	reqURL := fmt.Sprintf("%s/v2/%s/blobs/%s", c.baseURL(ref.Host), ref.Name, digest)
+	req, err := http.NewRequest(http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.doAuthenticated(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch blob %q for %s", digest, ref)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("fetching blob %q for %s returned status %d", digest, ref, resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// PushBlob uploads content to ref's repo if it is not already present, using the two-step
+// monolithic upload (POST to start the upload session, then PUT the content with its digest).
+func (c *RegistryClient) PushBlob(ref Ref, digest string, content []byte) error {
+	headReqURL := fmt.Sprintf("%s/v2/%s/blobs/%s", c.baseURL(ref.Host), ref.Name, digest)
+	headReq, err := http.NewRequest(http.MethodHead, headReqURL, nil)
+	if err != nil {
+		return err
+	}
+	if headResp, err := c.doAuthenticated(headReq); err == nil {
+		defer func() { _ = headResp.Body.Close() }()
+		if headResp.StatusCode == http.StatusOK {
+			// blob is already present in the repo.
+			return nil
+		}
+	}
+
+	startURL := fmt.Sprintf("%s/v2/%s/blobs/uploads/", c.baseURL(ref.Host), ref.Name)
+	startReq, err := http.NewRequest(http.MethodPost, startURL, nil)
+	if err != nil {
+		return err
+	}
+	startResp, err := c.doAuthenticated(startReq)
+	if err != nil {
+		return errors.Wrapf(err, "failed to start blob upload for %s", ref)
+	}
+	defer func() { _ = startResp.Body.Close() }()
+	if startResp.StatusCode != http.StatusAccepted {
+		return errors.Errorf("starting blob upload for %s returned status %d", ref, startResp.StatusCode)
+	}
+	uploadURL := startResp.Header.Get("Location")
+	if uploadURL == "" {
+		return errors.Errorf("blob upload response for %s did not include a Location header", ref)
+	}
+	if !strings.Contains(uploadURL, "?") {
+		uploadURL += "?"
+	} else {
+		uploadURL += "&"
+	}
+	uploadURL += "digest=" + url.QueryEscape(digest)
+
+	putReq, err := http.NewRequest(http.MethodPut, uploadURL, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	putReq.Header.Set("Content-Type", "application/octet-stream")
+	putResp, err := c.doAuthenticated(putReq)
+	if err != nil {
+		return errors.Wrapf(err, "failed to upload blob %q for %s", digest, ref)
+	}
+	defer func() { _ = putResp.Body.Close() }()
+	if putResp.StatusCode != http.StatusCreated {
+		return errors.Errorf("uploading blob %q for %s returned status %d", digest, ref, putResp.StatusCode)
+	}
+	return nil
+}
+
+// FetchManifestForPlatform fetches ref's manifest, resolving through an image index (manifest
+// list) to the entry matching platformOS/platformArch if ref points at a multi-platform image.
+func (c *RegistryClient) FetchManifestForPlatform(ref Ref, platformOS, platformArch string) (*Manifest, Descriptor, error) {
+	body, mediaType, err := c.FetchManifest(ref)
+	if err != nil {
+		return nil, Descriptor{}, err
+	}
+	if mediaType == MediaTypeImageIndex {
+		var index Index
+		if err := json.Unmarshal(body, &index); err != nil {
+			return nil, Descriptor{}, errors.Wrap(err, "failed to decode manifest index")
+		}
+		for _, desc := range index.Manifests {
+			if desc.Platform == nil {
+				continue
+			}
+			if desc.Platform.OS == platformOS && desc.Platform.Architecture == platformArch {
+				manifestBody, err := c.FetchBlob(ref, desc.Digest)
+				if err != nil {
+					return nil, Descriptor{}, err
+				}
+				var manifest Manifest
+				if err := json.Unmarshal(manifestBody, &manifest); err != nil {
+					return nil, Descriptor{}, errors.Wrap(err, "failed to decode platform manifest")
+				}
+				return &manifest, desc, nil
+			}
+		}
+		return nil, Descriptor{}, errors.Errorf("no manifest for platform %s/%s found in index for %s", platformOS, platformArch, ref)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, Descriptor{}, errors.Wrap(err, "failed to decode manifest")
+	}
+	return &manifest, Descriptor{MediaType: mediaType, Digest: "", Size: int64(len(body))}, nil
+}
+
+// DecodeImageConfig unmarshals a raw image config blob.
+func DecodeImageConfig(content []byte) (*ImageConfig, error) {
+	var cfg ImageConfig
+	if err := json.Unmarshal(content, &cfg); err != nil {
+		return nil, errors.Wrap(err, "failed to decode image config")
+	}
+	return &cfg, nil
+}
+
+// PushManifest uploads manifest (already-encoded JSON) as ref's tag.
+func (c *RegistryClient) PushManifest(ref Ref, mediaType string, manifest []byte) error {
+	reqURL := fmt.Sprintf("%s/v2/%s/manifests/%s", c.baseURL(ref.Host), ref.Name, ref.Tag)
+	req, err := http.NewRequest(http.MethodPut, reqURL, bytes.NewReader(manifest))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", mediaType)
+	resp, err := c.doAuthenticated(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to push manifest for %s", ref)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusCreated {
+		return errors.Errorf("pushing manifest for %s returned status %d", ref, resp.StatusCode)
+	}
+	return nil
+}
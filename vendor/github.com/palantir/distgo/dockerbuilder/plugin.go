@@ -0,0 +1,99 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dockerbuilder
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/palantir/distgo/distgo"
+	"github.com/palantir/distgo/plugin"
+)
+
+// dockerBuilderPluginPrefix is the executable name prefix that identifies a docker builder
+// plugin: a docker builder plugin advertising type name "foo" is an executable named
+// "godel-dockerbuilder-foo".
+const dockerBuilderPluginPrefix = "godel-dockerbuilder-"
+
+// registerPlugins discovers docker builder plugins on $PATH (and plugin.DirEnvVar) and registers
+// one under each plugin's advertised name.
+func registerPlugins(factory *distgo.DockerBuilderFactory) error {
+	for _, candidate := range plugin.Discover(dockerBuilderPluginPrefix) {
+		md, err := plugin.FetchMetadata(candidate.Path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to load docker builder plugin %q", candidate.Path)
+		}
+		path := candidate.Path
+		typeName := md.Name
+		if err := factory.Register(typeName, func(cfgYAML string) (distgo.DockerBuilder, error) {
+			return &pluginDockerBuilder{typeName: typeName, path: path, cfg: cfgYAML}, nil
+		}); err != nil {
+			return errors.Wrapf(err, "failed to register docker builder plugin %q", typeName)
+		}
+	}
+	return nil
+}
+
+// pluginDockerBuilderRunRequest is the JSON payload sent to a docker builder plugin's "run"
+// subcommand.
+//
+// Like pluginDisterRunRequest (see dister/plugin.go), this does not yet carry the full
+// distgo.ProjectInfo/distgo.ProductParam: distgo/dist.Products (the orchestrator that constructs
+// them) does not thread either through to the DockerBuilders it would call. A plugin that needs
+// information about a dependency's build/dist output must read it from the
+// DEP_PRODUCT_ID_*-prefixed environment variables forwarded into its process environment below.
+type pluginDockerBuilderRunRequest struct {
+	ProductName     string            `json:"productName"`
+	Version         string            `json:"version"`
+	BuildOutputDirs map[string]string `json:"buildOutputDirs"`
+	ContextDir      string            `json:"contextDir"`
+	Config          string            `json:"config"`
+}
+
+// pluginDockerBuilder is a distgo.DockerBuilder backed by an external
+// "godel-dockerbuilder-<name>" executable.
+type pluginDockerBuilder struct {
+	typeName string
+	path     string
+	cfg      string
+}
+
+// TypeName returns the name this plugin advertised in its metadata descriptor.
+func (b *pluginDockerBuilder) TypeName() (string, error) {
+	return b.typeName, nil
+}
+
+// RunDockerBuild invokes the plugin's "run" subcommand, passing
+// productName/version/buildOutputDirs/contextDir as a JSON request on stdin and the same
+// environment-variable contract a custom dist script receives -- VERSION plus (forwarded from this
+// process's own environment) any DEP_PRODUCT_ID_*-prefixed variables describing dependent
+// products' build/dist output -- streaming the plugin's build logs to w.
+func (b *pluginDockerBuilder) RunDockerBuild(productName, version string, buildOutputDirs map[string]string, contextDir string, w io.Writer) error {
+	req := pluginDockerBuilderRunRequest{
+		ProductName:     productName,
+		Version:         version,
+		BuildOutputDirs: buildOutputDirs,
+		ContextDir:      contextDir,
+		Config:          b.cfg,
+	}
+	env := append(os.Environ(), fmt.Sprintf("VERSION=%s", version))
+	if _, err := plugin.Run(b.path, req, env, w); err != nil {
+		return errors.Wrapf(err, "docker builder plugin %q failed", b.typeName)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dockerbuilder
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palantir/distgo/plugin"
+)
+
+const fakeDockerBuilderPlugin = `#!/usr/bin/env bash
+set -euo pipefail
+case "$1" in
+  metadata)
+    echo '{"SchemaVersion":"0.1.0","Name":"acme","Version":"1.0.0"}'
+    ;;
+  run)
+    cat >/dev/null
+    echo "built image for acme" >&2
+    ;;
+  *)
+    echo "unknown subcommand" >&2
+    exit 1
+    ;;
+esac
+`
+
+func TestNewDockerBuilderFactoryRegistersPlugins(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmp, "godel-dockerbuilder-acme"), []byte(fakeDockerBuilderPlugin), 0755))
+
+	require.NoError(t, os.Setenv(plugin.DirEnvVar, tmp))
+	defer func() { _ = os.Unsetenv(plugin.DirEnvVar) }()
+
+	factory, err := NewDockerBuilderFactory()
+	require.NoError(t, err)
+
+	builder, err := factory.NewDockerBuilder("acme", `{}`)
+	require.NoError(t, err)
+	typeName, err := builder.TypeName()
+	require.NoError(t, err)
+	assert.Equal(t, "acme", typeName)
+
+	var logs bytes.Buffer
+	err = builder.RunDockerBuild("foo", "1.2.3", map[string]string{}, tmp, &logs)
+	require.NoError(t, err)
+	assert.Contains(t, logs.String(), "built image for acme")
+}
+
+func TestPluginDockerBuilderForwardsDepProductIDEnvVars(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	const envProbePlugin = `#!/usr/bin/env bash
+set -euo pipefail
+case "$1" in
+  metadata)
+    echo '{"SchemaVersion":"0.1.0","Name":"acme","Version":"1.0.0"}'
+    ;;
+  run)
+    cat >/dev/null
+    echo "dep: $DEP_PRODUCT_ID_COUNT-$DEP_PRODUCT_ID_0" >&2
+    ;;
+  *)
+    echo "unknown subcommand" >&2
+    exit 1
+    ;;
+esac
+`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmp, "godel-dockerbuilder-acme"), []byte(envProbePlugin), 0755))
+
+	require.NoError(t, os.Setenv("DEP_PRODUCT_ID_COUNT", "1"))
+	defer func() { _ = os.Unsetenv("DEP_PRODUCT_ID_COUNT") }()
+	require.NoError(t, os.Setenv("DEP_PRODUCT_ID_0", "bar"))
+	defer func() { _ = os.Unsetenv("DEP_PRODUCT_ID_0") }()
+
+	b := &pluginDockerBuilder{typeName: "acme", path: filepath.Join(tmp, "godel-dockerbuilder-acme")}
+	var logs bytes.Buffer
+	require.NoError(t, b.RunDockerBuild("foo", "1.2.3", map[string]string{}, tmp, &logs))
+	assert.Contains(t, logs.String(), "dep: 1-bar")
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dockerbuilder
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/palantir/distgo/dockerbuilder/ocilayout"
+)
+
+// PushLayout pushes every manifest in the OCI layout at layoutDir to ref's repo, reusing ref's
+// tag for each platform manifest and, when more than one platform is present, pushing an index
+// that references all of them under ref's tag.
+//
+// distgo/dist.Products does not call PushLayout; it is called directly with the path of a layout,
+// such as the one the oci dister writes.
+func PushLayout(layoutDir string, ref ocilayout.Ref, insecure bool) error {
+	indexBytes, err := ioutil.ReadFile(filepath.Join(layoutDir, "index.json"))
+	if err != nil {
+		return errors.Wrapf(err, "failed to read index.json in %q", layoutDir)
+	}
+	var index ocilayout.Index
+	if err := json.Unmarshal(indexBytes, &index); err != nil {
+		return errors.Wrapf(err, "failed to decode index.json in %q", layoutDir)
+	}
+	if len(index.Manifests) == 0 {
+		return errors.Errorf("no manifests found in OCI layout %q", layoutDir)
+	}
+
+	client := ocilayout.NewRegistryClient()
+	client.Insecure = insecure
+
+	for _, manifestDesc := range index.Manifests {
+		manifestBytes, err := ioutil.ReadFile(filepath.Join(layoutDir, "blobs", "sha256", ocilayout.DigestHex(manifestDesc.Digest)))
+		if err != nil {
+			return errors.Wrapf(err, "failed to read manifest blob %q", manifestDesc.Digest)
+		}
+		var manifest ocilayout.Manifest
+		if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+			return errors.Wrapf(err, "failed to decode manifest blob %q", manifestDesc.Digest)
+		}
+
+		if err := pushBlobFromLayout(client, layoutDir, ref, manifest.Config); err != nil {
+			return err
+		}
+		for _, layer := range manifest.Layers {
+			if err := pushBlobFromLayout(client, layoutDir, ref, layer); err != nil {
+				return err
+			}
+		}
+		if err := client.PushManifest(ref, manifest.MediaType, manifestBytes); err != nil {
+			return errors.Wrapf(err, "failed to push manifest for platform %v", manifestDesc.Platform)
+		}
+	}
+
+	if len(index.Manifests) > 1 {
+		indexPayload, err := json.Marshal(index)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal index")
+		}
+		if err := client.PushManifest(ref, ocilayout.MediaTypeImageIndex, indexPayload); err != nil {
+			return errors.Wrap(err, "failed to push image index")
+		}
+	}
+	return nil
+}
+
+func pushBlobFromLayout(client *ocilayout.RegistryClient, layoutDir string, ref ocilayout.Ref, desc ocilayout.Descriptor) error {
+	blob, err := ioutil.ReadFile(filepath.Join(layoutDir, "blobs", "sha256", ocilayout.DigestHex(desc.Digest)))
+	if err != nil {
+		return errors.Wrapf(err, "failed to read blob %q", desc.Digest)
+	}
+	if err := client.PushBlob(ref, desc.Digest, blob); err != nil {
+		return errors.Wrapf(err, "failed to push blob %q", desc.Digest)
+	}
+	return nil
+}
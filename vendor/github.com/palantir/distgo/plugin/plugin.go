@@ -0,0 +1,137 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package plugin discovers and invokes third-party dister and docker-builder implementations
+// that are distributed as standalone executables, following the same external-plugin convention
+// as the Docker CLI: a plugin for dist type "foo" is an executable named "godel-dister-foo" (or,
+// for docker builders, "godel-dockerbuilder-foo") found on $PATH or in a configured plugin
+// directory. A plugin responds to a "metadata" subcommand with a JSON descriptor of itself, and
+// to a "run" subcommand with a JSON request on stdin describing the work to perform.
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SchemaVersion is the version of the metadata/run protocol implemented by this package.
+const SchemaVersion = "0.1.0"
+
+// DirEnvVar is the environment variable that, if set, provides additional directories (delimited
+// by the OS path-list separator, as in $PATH) to search for plugin executables, searched before
+// $PATH.
+const DirEnvVar = "GODEL_PLUGIN_DIR"
+
+// Metadata is the JSON descriptor that a plugin executable prints to stdout in response to being
+// invoked with a single "metadata" argument.
+type Metadata struct {
+	SchemaVersion string          `json:"SchemaVersion"`
+	Name          string          `json:"Name"`
+	Version       string          `json:"Version"`
+	ConfigSchema  json.RawMessage `json:"ConfigSchema,omitempty"`
+}
+
+// Candidate is a discovered plugin executable, named "<prefix><name>", that has not yet been
+// queried for its Metadata.
+type Candidate struct {
+	Name string
+	Path string
+}
+
+// Discover finds every executable file on $PATH (and the directories named by DirEnvVar) whose
+// name starts with prefix, returning one Candidate per distinct name in $PATH lookup order (a
+// match in an earlier directory shadows one of the same name in a later directory).
+func Discover(prefix string) []Candidate {
+	seen := map[string]bool{}
+	var candidates []Candidate
+	for _, dir := range searchDirs() {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue // unreadable or nonexistent directories are not an error; just skip them
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) {
+				continue
+			}
+			name := strings.TrimPrefix(entry.Name(), prefix)
+			if name == "" || seen[name] {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+				continue // not a regular executable file
+			}
+			seen[name] = true
+			candidates = append(candidates, Candidate{Name: name, Path: path})
+		}
+	}
+	return candidates
+}
+
+func searchDirs() []string {
+	var dirs []string
+	if extra := os.Getenv(DirEnvVar); extra != "" {
+		dirs = append(dirs, filepath.SplitList(extra)...)
+	}
+	dirs = append(dirs, filepath.SplitList(os.Getenv("PATH"))...)
+	return dirs
+}
+
+// FetchMetadata invokes the plugin executable at path with a single "metadata" argument and
+// decodes its stdout as a Metadata descriptor.
+func FetchMetadata(path string) (Metadata, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(path, "metadata")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return Metadata{}, errors.Wrapf(err, "failed to query metadata for plugin %q: %s", path, stderr.String())
+	}
+	var md Metadata
+	if err := json.Unmarshal(stdout.Bytes(), &md); err != nil {
+		return Metadata{}, errors.Wrapf(err, "plugin %q returned invalid metadata JSON", path)
+	}
+	if md.Name == "" {
+		return Metadata{}, errors.Errorf("plugin %q metadata did not include a Name", path)
+	}
+	return md, nil
+}
+
+// Run invokes the plugin executable at path with a single "run" argument, writing payload
+// (marshaled as JSON) to its stdin and env as its process environment. The plugin's stderr is
+// streamed to w as build logs; its stdout is captured and returned as the plugin's JSON result.
+func Run(path string, payload interface{}, env []string, w io.Writer) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to marshal run request for plugin %q", path)
+	}
+	var stdout bytes.Buffer
+	cmd := exec.Command(path, "run")
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout = &stdout
+	cmd.Stderr = w
+	cmd.Env = env
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "plugin %q run failed", path)
+	}
+	return stdout.Bytes(), nil
+}
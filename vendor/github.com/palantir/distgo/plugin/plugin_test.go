@@ -0,0 +1,95 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palantir/distgo/plugin"
+)
+
+const fakePlugin = `#!/usr/bin/env bash
+set -euo pipefail
+case "$1" in
+  metadata)
+    echo '{"SchemaVersion":"0.1.0","Name":"fake","Version":"1.0.0"}'
+    ;;
+  run)
+    request=$(cat)
+    echo "ran with: $request" >&2
+    echo '{"artifacts":["fake-artifact"]}'
+    ;;
+  *)
+    echo "unknown subcommand $1" >&2
+    exit 1
+    ;;
+esac
+`
+
+func writeFakePlugin(t *testing.T, dir, name string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(fakePlugin), 0755))
+	return path
+}
+
+func TestDiscoverAndRun(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	writeFakePlugin(t, tmp, "godel-dister-fake")
+
+	require.NoError(t, os.Setenv(plugin.DirEnvVar, tmp))
+	defer func() { _ = os.Unsetenv(plugin.DirEnvVar) }()
+
+	candidates := plugin.Discover("godel-dister-")
+	require.Len(t, candidates, 1)
+	assert.Equal(t, "fake", candidates[0].Name)
+
+	md, err := plugin.FetchMetadata(candidates[0].Path)
+	require.NoError(t, err)
+	assert.Equal(t, "fake", md.Name)
+	assert.Equal(t, "1.0.0", md.Version)
+
+	var logs bytes.Buffer
+	result, err := plugin.Run(candidates[0].Path, map[string]string{"hello": "world"}, os.Environ(), &logs)
+	require.NoError(t, err)
+	assert.Contains(t, logs.String(), `"hello":"world"`)
+	assert.JSONEq(t, `{"artifacts":["fake-artifact"]}`, string(result))
+}
+
+func TestDiscoverIgnoresNonMatchingAndNonExecutableFiles(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(tmp) }()
+
+	writeFakePlugin(t, tmp, "godel-dister-fake")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmp, "godel-dister-disabled"), []byte(fakePlugin), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmp, "not-a-plugin"), []byte("x"), 0755))
+
+	require.NoError(t, os.Setenv(plugin.DirEnvVar, tmp))
+	defer func() { _ = os.Unsetenv(plugin.DirEnvVar) }()
+
+	candidates := plugin.Discover("godel-dister-")
+	require.Len(t, candidates, 1)
+	assert.Equal(t, "fake", candidates[0].Name)
+}
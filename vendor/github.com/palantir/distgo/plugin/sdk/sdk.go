@@ -0,0 +1,64 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sdk implements the command-line side of the godel plugin protocol (see
+// github.com/palantir/distgo/plugin), so that a dister or docker-builder plugin's main() can be a
+// single call to Serve.
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/palantir/distgo/plugin"
+)
+
+// RunFunc implements a plugin's "run" subcommand: it receives the raw JSON request read from
+// stdin and must write its JSON result to stdout. A returned error aborts the plugin with a
+// non-zero exit code; anything written to stderr before returning is treated as build logs.
+type RunFunc func(request json.RawMessage, stdout io.Writer) error
+
+// Serve implements the godel plugin protocol's command-line entry point, dispatching on
+// os.Args[1]: "metadata" prints descriptor as JSON, "run" decodes the request from stdin and
+// invokes run. A plugin binary's main() should do nothing but call Serve.
+func Serve(descriptor plugin.Metadata, run RunFunc) {
+	if err := serve(os.Args, os.Stdin, os.Stdout, descriptor, run); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func serve(args []string, stdin io.Reader, stdout io.Writer, descriptor plugin.Metadata, run RunFunc) error {
+	if len(args) < 2 {
+		return errors.Errorf("usage: %s <metadata|run>", args[0])
+	}
+	switch args[1] {
+	case "metadata":
+		descriptor.SchemaVersion = plugin.SchemaVersion
+		return json.NewEncoder(stdout).Encode(descriptor)
+	case "run":
+		body, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return errors.Wrap(err, "failed to read run request from stdin")
+		}
+		return run(body, stdout)
+	default:
+		return errors.Errorf("unknown plugin subcommand %q", args[1])
+	}
+}
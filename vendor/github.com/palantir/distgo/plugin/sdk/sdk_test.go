@@ -0,0 +1,54 @@
+// Copyright 2016 Palantir Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/palantir/distgo/plugin"
+)
+
+func TestServeMetadata(t *testing.T) {
+	var stdout bytes.Buffer
+	err := serve([]string{"godel-dister-fake", "metadata"}, strings.NewReader(""), &stdout, plugin.Metadata{Name: "fake", Version: "1.0.0"}, nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"SchemaVersion":"0.1.0","Name":"fake","Version":"1.0.0"}`, stdout.String())
+}
+
+func TestServeRunDispatchesToRunFunc(t *testing.T) {
+	var stdout bytes.Buffer
+	var gotRequest json.RawMessage
+	run := func(request json.RawMessage, w io.Writer) error {
+		gotRequest = request
+		_, err := w.Write([]byte(`{"artifacts":[]}`))
+		return err
+	}
+	err := serve([]string{"godel-dister-fake", "run"}, strings.NewReader(`{"hello":"world"}`), &stdout, plugin.Metadata{Name: "fake"}, run)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"hello":"world"}`, string(gotRequest))
+	assert.Equal(t, `{"artifacts":[]}`, stdout.String())
+}
+
+func TestServeUnknownSubcommand(t *testing.T) {
+	err := serve([]string{"godel-dister-fake", "bogus"}, strings.NewReader(""), &bytes.Buffer{}, plugin.Metadata{Name: "fake"}, nil)
+	assert.Error(t, err)
+}